@@ -0,0 +1,232 @@
+package ast
+
+// Abstract syntax tree of a program. Identifiers hold the ids the lexer gave
+// their names and literals the ids of their constants, which the lexer that
+// read the program resolves.
+
+// Position defines where a node starts, as the lexer positions tokens
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Pos returns the position of the node
+func (p Position) Pos() Position {
+	return p
+}
+
+// Node is implemented by every node of the tree
+type Node interface {
+	Pos() Position
+}
+
+// Decl is implemented by declarations
+type Decl interface {
+	Node
+	declNode()
+}
+
+// Stmt is implemented by statements
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Expr is implemented by expressions
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Type is implemented by type expressions
+type Type interface {
+	Node
+	typeNode()
+}
+
+func (d *FuncDecl) declNode() {}
+func (d *VarDecl) declNode()  {}
+func (d *TypeDecl) declNode() {}
+
+func (s *Block) stmtNode()        {}
+func (s *AssignStmt) stmtNode()   {}
+func (s *IfStmt) stmtNode()       {}
+func (s *WhileStmt) stmtNode()    {}
+func (s *DoWhileStmt) stmtNode()  {}
+func (s *BreakStmt) stmtNode()    {}
+func (s *ContinueStmt) stmtNode() {}
+
+func (e *Ident) exprNode()       {}
+func (e *Literal) exprNode()     {}
+func (e *UnaryExpr) exprNode()   {}
+func (e *PostfixExpr) exprNode() {}
+func (e *BinaryExpr) exprNode()  {}
+func (e *CallExpr) exprNode()    {}
+func (e *IndexExpr) exprNode()   {}
+func (e *FieldExpr) exprNode()   {}
+
+func (t *TypeName) typeNode()   {}
+func (t *ArrayType) typeNode()  {}
+func (t *StructType) typeNode() {}
+
+// Program defines a whole program, a list of declarations
+type Program struct {
+	Position
+	Decls []Decl
+}
+
+// FuncDecl defines a function declaration
+type FuncDecl struct {
+	Position
+	Name   *Ident
+	Params []*Field
+	Result Type
+	Body   *Block
+}
+
+// Field defines a list of names sharing a type, as function parameters and
+// struct fields are declared
+type Field struct {
+	Position
+	Names []*Ident
+	Type  Type
+}
+
+// VarDecl defines a variables declaration
+type VarDecl struct {
+	Position
+	Names []*Ident
+	Type  Type
+}
+
+// TypeDecl defines a type declaration
+type TypeDecl struct {
+	Position
+	Name *Ident
+	Type Type
+}
+
+// TypeName defines a type referred to by name, Token being either one of
+// the builtin types' reserved words or ID, in which case Name is set
+type TypeName struct {
+	Position
+	Token int
+	Name  *Ident
+}
+
+// ArrayType defines an array type
+type ArrayType struct {
+	Position
+	Len  *Literal
+	Elem Type
+}
+
+// StructType defines a struct type
+type StructType struct {
+	Position
+	Fields []*Field
+}
+
+// Block defines a block, its variables declarations preceding its
+// statements
+type Block struct {
+	Position
+	Decls []*VarDecl
+	Stmts []Stmt
+}
+
+// AssignStmt defines an assignment
+type AssignStmt struct {
+	Position
+	Target Expr
+	Value  Expr
+}
+
+// IfStmt defines a selection, Else being nil when there is no else branch
+type IfStmt struct {
+	Position
+	Cond Expr
+	Then Stmt
+	Else Stmt
+}
+
+// WhileStmt defines a while loop
+type WhileStmt struct {
+	Position
+	Cond Expr
+	Body Stmt
+}
+
+// DoWhileStmt defines a do while loop
+type DoWhileStmt struct {
+	Position
+	Body Stmt
+	Cond Expr
+}
+
+// BreakStmt defines a break statement
+type BreakStmt struct {
+	Position
+}
+
+// ContinueStmt defines a continue statement
+type ContinueStmt struct {
+	Position
+}
+
+// Ident defines an identifier given the lexer's id for its name
+type Ident struct {
+	Position
+	Name int
+}
+
+// Literal defines a literal, Token being its token type and Value the id of
+// its constant
+type Literal struct {
+	Position
+	Token int
+	Value int
+}
+
+// UnaryExpr defines a prefix operation
+type UnaryExpr struct {
+	Position
+	Op int
+	X  Expr
+}
+
+// PostfixExpr defines a postfix increment or decrement
+type PostfixExpr struct {
+	Position
+	Op int
+	X  Expr
+}
+
+// BinaryExpr defines a binary operation
+type BinaryExpr struct {
+	Position
+	Op int
+	X  Expr
+	Y  Expr
+}
+
+// CallExpr defines a function call
+type CallExpr struct {
+	Position
+	Func *Ident
+	Args []Expr
+}
+
+// IndexExpr defines an array element access
+type IndexExpr struct {
+	Position
+	X     Expr
+	Index Expr
+}
+
+// FieldExpr defines a struct field access
+type FieldExpr struct {
+	Position
+	X     Expr
+	Field *Ident
+}
@@ -0,0 +1,440 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/lucbarr/sslang/diagnostics"
+	"github.com/lucbarr/sslang/lexical"
+	"github.com/lucbarr/sslang/scope"
+)
+
+// Checker walks the tree of a program resolving its names and checking its
+// types, collecting the issues it finds
+type Checker struct {
+	lexer *lexical.Lexer
+	scope *scope.Analyser
+	diags *diagnostics.Diagnostics
+
+	// loops is the number of loops enclosing the statement being checked
+	loops int
+	// vars is the number of variables of the function being checked, or of
+	// the global ones outside functions
+	vars int
+}
+
+// NewChecker builds a checker for programs read by lexer, which resolves
+// their identifiers and constants
+func NewChecker(lexer *lexical.Lexer) *Checker {
+	return &Checker{
+		lexer: lexer,
+		scope: &scope.Analyser{},
+		diags: &diagnostics.Diagnostics{},
+	}
+}
+
+// Check checks a whole program, returning the issues found in it
+func (c *Checker) Check(program *Program) *diagnostics.Diagnostics {
+	c.scope.Reset()
+	c.scope.InitBuiltins(c.lexer.Identifiers())
+	c.diags = &diagnostics.Diagnostics{}
+	c.vars = 0
+
+	for _, decl := range program.Decls {
+		switch decl := decl.(type) {
+		case *FuncDecl:
+			c.checkFuncDecl(decl)
+		case *TypeDecl:
+			c.checkTypeDecl(decl)
+		case *VarDecl:
+			c.checkVarDecl(decl)
+		}
+	}
+
+	return c.diags
+}
+
+// errorf collects an issue found at n
+func (c *Checker) errorf(n Node, format string, args ...interface{}) {
+	pos := n.Pos()
+	c.diags.Add(pos.Line, pos.Column, fmt.Sprintf(format, args...))
+}
+
+// name returns the name of an identifier
+func (c *Checker) name(id *Ident) string {
+	name, _ := c.lexer.IdentifierName(id.Name)
+	return name
+}
+
+// define defines the symbol id names in the current block, collecting a
+// redefinition if the block already defines it. The symbol is defined
+// anyway so that its uses resolve.
+func (c *Checker) define(id *Ident, kind scope.Kind, t scope.ObjectType) *scope.Object {
+	obj, err := c.scope.DefineSymbolChecked(id.Name)
+	if err != nil {
+		c.errorf(id, "%v: %s", err, c.name(id))
+		obj = c.scope.DefineSymbol(id.Name)
+	}
+
+	obj.DeclLine = id.Line
+	obj.DeclColumn = id.Column
+	obj.Kind = kind
+	obj.T = t
+	return obj
+}
+
+// expect collects a mismatch if a value of type got can't be used where one
+// of type want is expected
+func (c *Checker) expect(n Node, got, want *scope.Object) {
+	if c.scope.Coercible(got, want) {
+		return
+	}
+	c.errorf(n, "type mismatch: %v", c.scope.CompareTypes(got, want))
+}
+
+func (c *Checker) checkFuncDecl(decl *FuncDecl) {
+	fn := c.define(decl.Name, scope.KindFunction, scope.Function{})
+
+	c.scope.NewBlock()
+	globals := c.vars
+	c.vars = 0
+	n := 0
+	for _, param := range decl.Params {
+		t := c.resolveType(param.Type)
+		for _, name := range param.Names {
+			c.define(name, scope.KindParam, scope.Param{
+				PType: t,
+				Index: n,
+				Size:  c.scope.SizeOf(t),
+			})
+			n++
+		}
+	}
+
+	// parameters are the symbols of the block so far, the last one first
+	var params *scope.Object
+	if locals := c.scope.LocalSymbols(); len(locals) > 0 {
+		params = locals[0]
+	}
+	fn.T = scope.Function{
+		PRetType: c.resolveType(decl.Result),
+		PParams:  params,
+		Params:   n,
+	}
+
+	// the body shares the parameters' block, so locals can't redefine them
+	c.checkBlockBody(decl.Body)
+	c.scope.EndBlock()
+
+	f := fn.T.(scope.Function)
+	f.Vars = c.vars
+	fn.T = f
+	c.vars = globals
+}
+
+func (c *Checker) checkTypeDecl(decl *TypeDecl) {
+	t := c.resolveType(decl.Type)
+
+	switch decl.Type.(type) {
+	case *ArrayType, *StructType:
+		c.define(decl.Name, t.Kind, t.T)
+	default:
+		c.define(decl.Name, scope.KindAliasType, scope.Alias{
+			BaseType: t,
+			Size:     c.scope.SizeOf(t),
+		})
+	}
+}
+
+func (c *Checker) checkVarDecl(decl *VarDecl) {
+	t := c.resolveType(decl.Type)
+	for _, name := range decl.Names {
+		c.define(name, scope.KindVar, scope.Var{
+			PType: t,
+			Index: c.vars,
+			Size:  c.scope.SizeOf(t),
+		})
+		c.vars++
+	}
+}
+
+// builtinTypes maps the reserved words naming types to the scalar types
+var builtinTypes = map[int]*scope.Object{
+	lexical.Integer: scope.PIntObj,
+	lexical.Char:    scope.PCharObj,
+	lexical.Boolean: scope.PBoolObj,
+	lexical.String:  scope.PStringObj,
+}
+
+// resolveType returns the type object t denotes, the universal type if it
+// denotes none
+func (c *Checker) resolveType(t Type) *scope.Object {
+	switch t := t.(type) {
+	case *TypeName:
+		if t.Name == nil {
+			return builtinTypes[t.Token]
+		}
+
+		obj, err := c.scope.RequireSymbol(t.Name.Name)
+		if err != nil {
+			c.errorf(t, "%v: %s", err, c.name(t.Name))
+			return scope.PUniversalObj
+		}
+		if !obj.Kind.IsType() {
+			c.errorf(t, "%s is not a type", c.name(t.Name))
+			return scope.PUniversalObj
+		}
+		c.scope.MarkUsed(obj)
+		return obj
+	case *ArrayType:
+		elem := c.resolveType(t.Elem)
+		if constantType, _ := c.lexer.ConstantType(t.Len.Value); constantType == lexical.BigNumeral {
+			c.errorf(t.Len, "array length out of range")
+			return scope.PUniversalObj
+		}
+		n := c.lexer.GetNumeralConstant(t.Len.Value)
+		return &scope.Object{
+			Kind: scope.KindArrayType,
+			T: scope.Array{
+				ElemType:    elem,
+				NumElements: n,
+				Size:        n * c.scope.SizeOf(elem),
+			},
+		}
+	case *StructType:
+		// fields are defined in a block of their own, so duplicates are
+		// reported as redefinitions
+		c.scope.NewBlock()
+		size := 0
+		for _, field := range t.Fields {
+			fieldType := c.resolveType(field.Type)
+			for _, name := range field.Names {
+				c.define(name, scope.KindField, scope.Field{
+					PType: fieldType,
+					Index: size,
+					Size:  c.scope.SizeOf(fieldType),
+				})
+				size += c.scope.SizeOf(fieldType)
+			}
+		}
+
+		var fields *scope.Object
+		if locals := c.scope.LocalSymbols(); len(locals) > 0 {
+			fields = locals[0]
+		}
+		c.scope.EndBlock()
+
+		return &scope.Object{
+			Kind: scope.KindStructType,
+			T: scope.Struct{
+				Fields: fields,
+				Size:   size,
+			},
+		}
+	}
+
+	return scope.PUniversalObj
+}
+
+// checkBlockBody checks the declarations and statements of a block in the
+// current scope block
+func (c *Checker) checkBlockBody(block *Block) {
+	for _, decl := range block.Decls {
+		c.checkVarDecl(decl)
+	}
+	for _, stmt := range block.Stmts {
+		c.checkStmt(stmt)
+	}
+}
+
+func (c *Checker) checkStmt(stmt Stmt) {
+	switch stmt := stmt.(type) {
+	case *Block:
+		c.scope.NewBlock()
+		c.checkBlockBody(stmt)
+		c.scope.EndBlock()
+	case *AssignStmt:
+		target := c.checkAssignable(stmt.Target)
+		value := c.checkExpr(stmt.Value)
+		c.expect(stmt.Value, value, target)
+	case *IfStmt:
+		c.expect(stmt.Cond, c.checkExpr(stmt.Cond), scope.PBoolObj)
+		c.checkStmt(stmt.Then)
+		if stmt.Else != nil {
+			c.checkStmt(stmt.Else)
+		}
+	case *WhileStmt:
+		c.expect(stmt.Cond, c.checkExpr(stmt.Cond), scope.PBoolObj)
+		c.checkLoopBody(stmt.Body)
+	case *DoWhileStmt:
+		c.checkLoopBody(stmt.Body)
+		c.expect(stmt.Cond, c.checkExpr(stmt.Cond), scope.PBoolObj)
+	case *BreakStmt:
+		if c.loops == 0 {
+			c.errorf(stmt, "break outside a loop")
+		}
+	case *ContinueStmt:
+		if c.loops == 0 {
+			c.errorf(stmt, "continue outside a loop")
+		}
+	}
+}
+
+func (c *Checker) checkLoopBody(body Stmt) {
+	c.loops++
+	c.checkStmt(body)
+	c.loops--
+}
+
+// checkAssignable checks an expression that is assigned to, returning its
+// type
+func (c *Checker) checkAssignable(e Expr) *scope.Object {
+	if id, ok := e.(*Ident); ok {
+		if obj := c.scope.SearchVisibleSymbol(id.Name); obj != nil && !obj.IsMutable() {
+			c.errorf(id, "cannot assign to %s", c.name(id))
+			return scope.PUniversalObj
+		}
+	}
+	return c.checkExpr(e)
+}
+
+// checkExpr checks an expression, returning its type. Expressions whose
+// type can't be told are of the universal type, so that a single mistake
+// isn't reported over and over.
+func (c *Checker) checkExpr(e Expr) *scope.Object {
+	switch e := e.(type) {
+	case *Ident:
+		return c.checkIdent(e)
+	case *Literal:
+		switch e.Token {
+		case lexical.Numeral:
+			return scope.PIntObj
+		case lexical.Character:
+			return scope.PCharObj
+		case lexical.Stringval:
+			return scope.PStringObj
+		case lexical.True, lexical.False:
+			return scope.PBoolObj
+		}
+	case *UnaryExpr:
+		x := c.checkExpr(e.X)
+		if e.Op == lexical.Not {
+			c.expect(e.X, x, scope.PBoolObj)
+			return scope.PBoolObj
+		}
+		c.expect(e.X, x, scope.PIntObj)
+		return scope.PIntObj
+	case *PostfixExpr:
+		c.expect(e.X, c.checkExpr(e.X), scope.PIntObj)
+		return scope.PIntObj
+	case *BinaryExpr:
+		return c.checkBinary(e)
+	case *CallExpr:
+		return c.checkCall(e)
+	case *IndexExpr:
+		x := c.checkExpr(e.X)
+		c.expect(e.Index, c.checkExpr(e.Index), scope.PIntObj)
+		if x == scope.PUniversalObj {
+			return x
+		}
+
+		elem, ok := c.scope.ElementType(x)
+		if !ok {
+			c.errorf(e, "indexing a value of kind %v", x.Kind)
+			return scope.PUniversalObj
+		}
+		return elem
+	case *FieldExpr:
+		x := c.checkExpr(e.X)
+		if x == scope.PUniversalObj {
+			return x
+		}
+
+		t, err := c.scope.ResolveFieldPath(x, []int{e.Field.Name})
+		if err != nil {
+			c.errorf(e.Field, "no field %s", c.name(e.Field))
+			return scope.PUniversalObj
+		}
+		return t
+	}
+
+	return scope.PUniversalObj
+}
+
+func (c *Checker) checkIdent(id *Ident) *scope.Object {
+	obj, err := c.scope.RequireSymbol(id.Name)
+	if err != nil {
+		c.errorf(id, "%v: %s", err, c.name(id))
+		return scope.PUniversalObj
+	}
+	c.scope.MarkUsed(obj)
+
+	switch t := obj.T.(type) {
+	case scope.Var:
+		return t.PType
+	case scope.Param:
+		return t.PType
+	case scope.Const:
+		return t.PType
+	}
+
+	c.errorf(id, "%s is not a value", c.name(id))
+	return scope.PUniversalObj
+}
+
+func (c *Checker) checkBinary(e *BinaryExpr) *scope.Object {
+	x := c.checkExpr(e.X)
+	y := c.checkExpr(e.Y)
+
+	switch e.Op {
+	case lexical.And, lexical.Or:
+		c.expect(e.X, x, scope.PBoolObj)
+		c.expect(e.Y, y, scope.PBoolObj)
+		return scope.PBoolObj
+	case lexical.EqualEqual, lexical.NotEqual:
+		if !c.scope.Coercible(x, y) && !c.scope.Coercible(y, x) {
+			c.errorf(e, "type mismatch: %v", c.scope.CompareTypes(x, y))
+		}
+		return scope.PBoolObj
+	case lexical.LessThan, lexical.GreaterThan, lexical.LessOrEqual, lexical.GreaterOrEqual:
+		c.expect(e.X, x, scope.PIntObj)
+		c.expect(e.Y, y, scope.PIntObj)
+		return scope.PBoolObj
+	}
+
+	c.expect(e.X, x, scope.PIntObj)
+	c.expect(e.Y, y, scope.PIntObj)
+	return scope.PIntObj
+}
+
+func (c *Checker) checkCall(e *CallExpr) *scope.Object {
+	args := []*scope.Object{}
+	for _, arg := range e.Args {
+		args = append(args, c.checkExpr(arg))
+	}
+
+	obj, err := c.scope.RequireSymbol(e.Func.Name)
+	if err != nil {
+		c.errorf(e.Func, "%v: %s", err, c.name(e.Func))
+		return scope.PUniversalObj
+	}
+	c.scope.MarkUsed(obj)
+
+	fn, ok := obj.T.(scope.Function)
+	if !ok {
+		c.errorf(e.Func, "%s is not a function", c.name(e.Func))
+		return scope.PUniversalObj
+	}
+	if fn.Params != len(args) {
+		c.errorf(e, "%s takes %d arguments, got %d", c.name(e.Func), fn.Params, len(args))
+		return fn.PRetType
+	}
+
+	// the parameters list holds the last parameter first
+	param := fn.PParams
+	for i := len(args) - 1; i >= 0 && param != nil; i-- {
+		c.expect(e.Args[i], args[i], param.T.(scope.Param).PType)
+		param = param.Next
+	}
+
+	return fn.PRetType
+}
@@ -0,0 +1,176 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/lucbarr/sslang/diagnostics"
+	"github.com/lucbarr/sslang/lexical"
+	"github.com/lucbarr/sslang/scope"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		diags []diagnostics.Diagnostic
+	}{
+		"test sample program": {
+			program: `
+function main(arg:integer):integer
+{
+	var a:integer;
+	var b:integer;
+	var c:integer;
+	b = 1;
+	c = 2;
+}`,
+			diags: []diagnostics.Diagnostic{},
+		},
+		"test well typed program": {
+			program: `type Point = struct { x, y: integer; tag: char }
+type Points = array [4] of Point
+type Count = unsigned
+var n: integer;
+function dist(p: Point, q: Point): integer {
+	var d: integer;
+	d = p.x - q.x;
+	if (d < 0) d = -d;
+}
+function main(arg: integer): integer {
+	var ps: Points;
+	var ok: boolean;
+	var i: integer;
+	i = 0;
+	while (i < 4) {
+		ps[i].x = i * 2;
+		ps[i].y = ps[i].tag;
+		i = i + 1;
+		if (i == 3) break;
+	}
+	ok = dist(ps[0], ps[1]) > n && !(i != 4);
+}`,
+			diags: []diagnostics.Diagnostic{},
+		},
+		"test ill typed assignment": {
+			program: "function f(x: integer): integer {\n\tvar b: boolean;\n\tb = x + 1;\n}",
+			diags: []diagnostics.Diagnostic{
+				{Line: 2, Column: 6, Message: "type mismatch: scalar int vs bool"},
+			},
+		},
+		"test undefined names": {
+			program: "function f(x: integer): T {\n\ty = x;\n\tx = g(x);\n}",
+			diags: []diagnostics.Diagnostic{
+				{Line: 0, Column: 25, Message: "undefined symbol: T"},
+				{Line: 1, Column: 2, Message: "undefined symbol: y"},
+				{Line: 2, Column: 6, Message: "undefined symbol: g"},
+			},
+		},
+		"test redefinitions": {
+			program: "var a: integer;\nvar a: char;\nfunction f(x: integer): integer {\n\tvar x: integer;\n}",
+			diags: []diagnostics.Diagnostic{
+				{Line: 1, Column: 5, Message: "symbol already defined in this block: a"},
+				{Line: 3, Column: 6, Message: "symbol already defined in this block: x"},
+			},
+		},
+		"test conditions and calls": {
+			program: `function f(x: integer, c: char): integer {
+	while (x) continue;
+	x = f(1);
+	x = f(c, 1);
+	break;
+	f = x;
+}`,
+			diags: []diagnostics.Diagnostic{
+				{Line: 1, Column: 9, Message: "type mismatch: scalar int vs bool"},
+				{Line: 2, Column: 6, Message: "f takes 2 arguments, got 1"},
+				{Line: 3, Column: 11, Message: "type mismatch: scalar int vs char"},
+				{Line: 4, Column: 2, Message: "break outside a loop"},
+				{Line: 5, Column: 2, Message: "cannot assign to f"},
+			},
+		},
+		"test array length out of range": {
+			program: "type A = array [999999999999999999999999999999] of integer",
+			diags: []diagnostics.Diagnostic{
+				{Line: 0, Column: 17, Message: "array length out of range"},
+			},
+		},
+		"test fields and elements": {
+			program: "type S = struct { a: integer }\nvar s: S;\nvar n: integer;\nfunction f(x: integer): integer {\n\tx = s.b;\n\tx = n[0];\n}",
+			diags: []diagnostics.Diagnostic{
+				{Line: 4, Column: 8, Message: "no field b"},
+				{Line: 5, Column: 6, Message: "indexing a value of kind ScalarType"},
+			},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := lexical.NewLexer([]byte(table.program))
+			program, err := NewParser(lexer).Parse()
+			assert.Nil(t, err)
+
+			diags := NewChecker(lexer).Check(program)
+			assert.Equal(t, table.diags, diags.Sorted())
+			assert.Equal(t, len(table.diags) > 0, diags.HasErrors())
+		})
+	}
+}
+
+func TestCheckVariableIndices(t *testing.T) {
+	lexer := lexical.NewLexer([]byte("var a: integer;\nfunction f(x: integer): integer {\n\tvar y, z: integer;\n}\nvar b: integer;"))
+	program, err := NewParser(lexer).Parse()
+	assert.Nil(t, err)
+
+	checker := NewChecker(lexer)
+	assert.False(t, checker.Check(program).HasErrors())
+
+	names := lexer.Identifiers()
+	assert.Equal(t, 0, checker.scope.SearchGlobalSymbol(names["a"]).T.(scope.Var).Index)
+	assert.Equal(t, 1, checker.scope.SearchGlobalSymbol(names["b"]).T.(scope.Var).Index)
+	assert.Equal(t, 2, checker.scope.SearchGlobalSymbol(names["f"]).T.(scope.Function).Vars)
+}
+
+func TestCheckBuiltinTypes(t *testing.T) {
+	lexer := lexical.NewLexer([]byte("var i: integer; var c: char; var b: boolean; var s: string; var u: unsigned;"))
+	program, err := NewParser(lexer).Parse()
+	assert.Nil(t, err)
+
+	checker := NewChecker(lexer)
+	assert.False(t, checker.Check(program).HasErrors())
+
+	tt := map[string]struct {
+		name string
+
+		t *scope.Object
+	}{
+		"test integer": {
+			name: "i",
+			t:    scope.PIntObj,
+		},
+		"test char": {
+			name: "c",
+			t:    scope.PCharObj,
+		},
+		"test boolean": {
+			name: "b",
+			t:    scope.PBoolObj,
+		},
+		"test string": {
+			name: "s",
+			t:    scope.PStringObj,
+		},
+		"test unsigned": {
+			name: "u",
+			t:    scope.PUnsignedObj,
+		},
+	}
+
+	names := lexer.Identifiers()
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			obj := checker.scope.SearchGlobalSymbol(names[table.name])
+			assert.True(t, checker.scope.CheckTypes(obj.T.(scope.Var).PType, table.t))
+		})
+	}
+}
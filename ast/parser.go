@@ -0,0 +1,889 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/lucbarr/sslang/lexical"
+)
+
+// Error defines a syntax error and where it happened, positioned as the
+// lexer positions tokens
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Parser builds the tree of a program out of the tokens of a lexer
+type Parser struct {
+	lexer *lexical.Lexer
+
+	// recovering is set while parsing through errors, which are collected
+	// in errs
+	recovering bool
+	errs       []error
+}
+
+// NewParser builds a parser reading tokens from lexer
+func NewParser(lexer *lexical.Lexer) *Parser {
+	return &Parser{
+		lexer: lexer,
+	}
+}
+
+// Parse parses a whole program. It stops at the first lexical or syntax
+// error, returning it.
+func (p *Parser) Parse() (*Program, error) {
+	p.recovering = false
+	return p.parseProgram()
+}
+
+// ParseCollectErrors parses a whole program without stopping at errors.
+// Declarations and statements that fail to parse are left out of the tree
+// and their errors collected, parsing resuming at the next synchronizing
+// token, see sync. Errors that can't be parsed past, such as the program
+// failing to be read, stop parsing, the program then being nil.
+func (p *Parser) ParseCollectErrors() (*Program, []error) {
+	p.recovering = true
+	p.errs = []error{}
+
+	program, err := p.parseProgram()
+	if err != nil {
+		p.errs = append(p.errs, err)
+	}
+	return program, p.errs
+}
+
+func (p *Parser) parseProgram() (*Program, error) {
+	// a failed token is left peeked, its error being handled in the loop
+	tok, _ := p.peek()
+
+	program := &Program{Position: pos(tok)}
+	for {
+		tok, err := p.peek()
+		if err != nil {
+			if err = p.sync(err, lexical.Function, lexical.Type, lexical.Var); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if tok.Type == lexical.EOF {
+			return program, nil
+		}
+
+		decl, err := p.parseDecl()
+		if err != nil {
+			// declarations may be nested in a function whose header failed
+			// to parse, so parsing resumes at the next one only
+			if err = p.sync(err, lexical.Function, lexical.Type); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		program.Decls = append(program.Decls, decl)
+	}
+}
+
+// sync collects err and skips tokens up to the next synchronizing token:
+// past the next Semicolon or RightBraces, or up to the next of stops or EOF.
+// A token that failed to lex is skipped along, lexical errors found while
+// skipping being collected too. It returns the error parsing stops at
+// instead: err as is when the parser isn't recovering from errors, or any
+// error that is neither a syntax nor a lexical one, such as the program
+// failing to be read, which skipping tokens can't get past.
+func (p *Parser) sync(err error, stops ...int) error {
+	if !p.recovering || !recoverable(err) {
+		return err
+	}
+	p.errs = append(p.errs, err)
+
+	if _, ok := err.(*lexical.LexError); ok {
+		p.lexer.NextTokenFull()
+	}
+
+	for {
+		tok, err := p.peek()
+		if err != nil {
+			if _, ok := err.(*lexical.LexError); !ok {
+				return err
+			}
+			p.errs = append(p.errs, err)
+			p.lexer.NextTokenFull()
+			continue
+		}
+		if tok.Type == lexical.EOF || contains(stops, tok.Type) {
+			return nil
+		}
+
+		p.lexer.NextTokenFull()
+		if tok.Type == lexical.Semicolon || tok.Type == lexical.RightBraces {
+			return nil
+		}
+	}
+}
+
+// recoverable returns true if parsing can resume past err
+func recoverable(err error) bool {
+	switch err.(type) {
+	case *Error, *lexical.LexError:
+		return true
+	}
+	return false
+}
+
+func contains(tokens []int, tok int) bool {
+	for _, t := range tokens {
+		if t == tok {
+			return true
+		}
+	}
+	return false
+}
+
+// peek returns the next token without consuming it
+func (p *Parser) peek() (lexical.Token, error) {
+	return p.lexer.PeekToken()
+}
+
+// next consumes the next token
+func (p *Parser) next() (lexical.Token, error) {
+	tok, err := p.lexer.PeekToken()
+	if err != nil {
+		return tok, err
+	}
+	_, _, err = p.lexer.NextTokenFull()
+	return tok, err
+}
+
+// accept consumes the next token if it is of type t, returning whether it
+// did
+func (p *Parser) accept(t int) (lexical.Token, bool, error) {
+	tok, err := p.peek()
+	if err != nil || tok.Type != t {
+		return tok, false, err
+	}
+	tok, err = p.next()
+	return tok, err == nil, err
+}
+
+// expect consumes the next token, failing if it isn't of type t
+func (p *Parser) expect(t int) (lexical.Token, error) {
+	tok, ok, err := p.accept(t)
+	if err != nil {
+		return tok, err
+	}
+	if !ok {
+		return tok, unexpected(tok, lexical.TokenName(t))
+	}
+	return tok, nil
+}
+
+// unexpected builds the error for finding tok where what was expected
+func unexpected(tok lexical.Token, what string) error {
+	return &Error{
+		Line:    tok.Line,
+		Column:  tok.Column,
+		Message: fmt.Sprintf("expected %s, found %s", what, lexical.TokenName(tok.Type)),
+	}
+}
+
+// pos returns the position of tok
+func pos(tok lexical.Token) Position {
+	return Position{Line: tok.Line, Column: tok.Column}
+}
+
+func (p *Parser) parseIdent() (*Ident, error) {
+	tok, err := p.expect(lexical.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Ident{Position: pos(tok), Name: tok.Secondary}, nil
+}
+
+// parseDecl parses ED = FD | TD | VD
+func (p *Parser) parseDecl() (Decl, error) {
+	tok, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok.Type {
+	case lexical.Function:
+		return p.parseFuncDecl()
+	case lexical.Type:
+		return p.parseTypeDecl()
+	case lexical.Var:
+		return p.parseVarDecl()
+	}
+
+	return nil, unexpected(tok, "declaration")
+}
+
+// parseFuncDecl parses FD = 'function' ID '(' LP ')' ':' T B
+func (p *Parser) parseFuncDecl() (*FuncDecl, error) {
+	tok, err := p.expect(lexical.Function)
+	if err != nil {
+		return nil, err
+	}
+
+	decl := &FuncDecl{Position: pos(tok)}
+	if decl.Name, err = p.parseIdent(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(lexical.LeftParenthesis); err != nil {
+		return nil, err
+	}
+
+	for {
+		param, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		decl.Params = append(decl.Params, param)
+
+		_, ok, err := p.accept(lexical.Comma)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+	}
+
+	if _, err = p.expect(lexical.RightParenthesis); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(lexical.Colon); err != nil {
+		return nil, err
+	}
+	if decl.Result, err = p.parseType(); err != nil {
+		return nil, err
+	}
+	if decl.Body, err = p.parseBlock(); err != nil {
+		return nil, err
+	}
+
+	return decl, nil
+}
+
+// parseField parses a single name and its type, ID ':' T
+func (p *Parser) parseField() (*Field, error) {
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(lexical.Colon); err != nil {
+		return nil, err
+	}
+	t, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Field{Position: name.Position, Names: []*Ident{name}, Type: t}, nil
+}
+
+// parseIdentList parses LI = LI ',' ID | ID
+func (p *Parser) parseIdentList() ([]*Ident, error) {
+	names := []*Ident{}
+	for {
+		name, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+
+		_, ok, err := p.accept(lexical.Comma)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return names, nil
+		}
+	}
+}
+
+// parseTypeDecl parses
+//
+//	TD = 'type' ID '=' 'array' '[' NUM ']' 'of' T
+//	   | 'type' ID '=' 'struct' '{' DC '}'
+//	   | 'type' ID '=' T
+func (p *Parser) parseTypeDecl() (*TypeDecl, error) {
+	tok, err := p.expect(lexical.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	decl := &TypeDecl{Position: pos(tok)}
+	if decl.Name, err = p.parseIdent(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(lexical.Equals); err != nil {
+		return nil, err
+	}
+
+	tok, err = p.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok.Type {
+	case lexical.Array:
+		decl.Type, err = p.parseArrayType()
+	case lexical.Struct:
+		decl.Type, err = p.parseStructType()
+	default:
+		decl.Type, err = p.parseType()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decl, nil
+}
+
+func (p *Parser) parseArrayType() (*ArrayType, error) {
+	tok, err := p.expect(lexical.Array)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &ArrayType{Position: pos(tok)}
+	if _, err = p.expect(lexical.LeftSquare); err != nil {
+		return nil, err
+	}
+	num, err := p.expect(lexical.Numeral)
+	if err != nil {
+		return nil, err
+	}
+	t.Len = &Literal{Position: pos(num), Token: num.Type, Value: num.Secondary}
+	if _, err = p.expect(lexical.RightSquare); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(lexical.Of); err != nil {
+		return nil, err
+	}
+	if t.Elem, err = p.parseType(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// parseStructType parses 'struct' '{' DC '}', DC = DC ';' LI ':' T | LI ':' T
+func (p *Parser) parseStructType() (*StructType, error) {
+	tok, err := p.expect(lexical.Struct)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &StructType{Position: pos(tok)}
+	if _, err = p.expect(lexical.LeftBraces); err != nil {
+		return nil, err
+	}
+
+	for {
+		names, err := p.parseIdentList()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(lexical.Colon); err != nil {
+			return nil, err
+		}
+		fieldType, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		t.Fields = append(t.Fields, &Field{Position: names[0].Position, Names: names, Type: fieldType})
+
+		_, ok, err := p.accept(lexical.Semicolon)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+	}
+
+	if _, err = p.expect(lexical.RightBraces); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// parseType parses T = 'integer' | 'char' | 'boolean' | 'string' | ID
+func (p *Parser) parseType() (Type, error) {
+	tok, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok.Type {
+	case lexical.Integer, lexical.Char, lexical.Boolean, lexical.String:
+		if _, err = p.next(); err != nil {
+			return nil, err
+		}
+		return &TypeName{Position: pos(tok), Token: tok.Type}, nil
+	case lexical.ID:
+		name, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		return &TypeName{Position: name.Position, Token: lexical.ID, Name: name}, nil
+	}
+
+	return nil, unexpected(tok, "type")
+}
+
+// parseVarDecl parses VD = 'var' LI ':' T ';'
+func (p *Parser) parseVarDecl() (*VarDecl, error) {
+	tok, err := p.expect(lexical.Var)
+	if err != nil {
+		return nil, err
+	}
+
+	decl := &VarDecl{Position: pos(tok)}
+	if decl.Names, err = p.parseIdentList(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(lexical.Colon); err != nil {
+		return nil, err
+	}
+	if decl.Type, err = p.parseType(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(lexical.Semicolon); err != nil {
+		return nil, err
+	}
+
+	return decl, nil
+}
+
+// parseBlock parses B = '{' LVD LS '}'
+func (p *Parser) parseBlock() (*Block, error) {
+	tok, err := p.expect(lexical.LeftBraces)
+	if err != nil {
+		return nil, err
+	}
+
+	block := &Block{Position: pos(tok)}
+	for {
+		tok, err = p.peek()
+		if err != nil {
+			if err = p.sync(err, lexical.RightBraces); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if tok.Type != lexical.Var {
+			break
+		}
+
+		decl, err := p.parseVarDecl()
+		if err != nil {
+			if err = p.sync(err, lexical.RightBraces); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		block.Decls = append(block.Decls, decl)
+	}
+
+	for {
+		tok, err = p.peek()
+		if err != nil {
+			if err = p.sync(err, lexical.RightBraces); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if tok.Type == lexical.RightBraces || tok.Type == lexical.EOF {
+			break
+		}
+
+		stmt, err := p.parseStmt()
+		if err != nil {
+			if err = p.sync(err, lexical.RightBraces); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		block.Stmts = append(block.Stmts, stmt)
+	}
+
+	if _, err = p.expect(lexical.RightBraces); err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// parseStmt parses
+//
+//	S = 'if' '(' E ')' S
+//	  | 'if' '(' E ')' S 'else' S
+//	  | 'while' '(' E ')' S
+//	  | 'do' S 'while' '(' E ')' ';'
+//	  | B
+//	  | VL '=' E ';'
+//	  | 'break' ';'
+//	  | 'continue' ';'
+func (p *Parser) parseStmt() (Stmt, error) {
+	tok, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok.Type {
+	case lexical.If:
+		return p.parseIfStmt()
+	case lexical.While:
+		return p.parseWhileStmt()
+	case lexical.Do:
+		return p.parseDoWhileStmt()
+	case lexical.LeftBraces:
+		return p.parseBlock()
+	case lexical.Break, lexical.Continue:
+		if _, err = p.next(); err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(lexical.Semicolon); err != nil {
+			return nil, err
+		}
+		if tok.Type == lexical.Break {
+			return &BreakStmt{Position: pos(tok)}, nil
+		}
+		return &ContinueStmt{Position: pos(tok)}, nil
+	case lexical.ID:
+		return p.parseAssignStmt()
+	}
+
+	return nil, unexpected(tok, "statement")
+}
+
+// parseCond parses a parenthesized condition, '(' E ')'
+func (p *Parser) parseCond() (Expr, error) {
+	if _, err := p.expect(lexical.LeftParenthesis); err != nil {
+		return nil, err
+	}
+	cond, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(lexical.RightParenthesis); err != nil {
+		return nil, err
+	}
+	return cond, nil
+}
+
+func (p *Parser) parseIfStmt() (*IfStmt, error) {
+	tok, err := p.expect(lexical.If)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &IfStmt{Position: pos(tok)}
+	if stmt.Cond, err = p.parseCond(); err != nil {
+		return nil, err
+	}
+	if stmt.Then, err = p.parseStmt(); err != nil {
+		return nil, err
+	}
+
+	// else binds to the nearest if
+	_, ok, err := p.accept(lexical.Else)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if stmt.Else, err = p.parseStmt(); err != nil {
+			return nil, err
+		}
+	}
+
+	return stmt, nil
+}
+
+func (p *Parser) parseWhileStmt() (*WhileStmt, error) {
+	tok, err := p.expect(lexical.While)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &WhileStmt{Position: pos(tok)}
+	if stmt.Cond, err = p.parseCond(); err != nil {
+		return nil, err
+	}
+	if stmt.Body, err = p.parseStmt(); err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+func (p *Parser) parseDoWhileStmt() (*DoWhileStmt, error) {
+	tok, err := p.expect(lexical.Do)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &DoWhileStmt{Position: pos(tok)}
+	if stmt.Body, err = p.parseStmt(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(lexical.While); err != nil {
+		return nil, err
+	}
+	if stmt.Cond, err = p.parseCond(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(lexical.Semicolon); err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+func (p *Parser) parseAssignStmt() (*AssignStmt, error) {
+	target, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &AssignStmt{Position: target.Pos(), Target: target}
+	if _, err = p.expect(lexical.Equals); err != nil {
+		return nil, err
+	}
+	if stmt.Value, err = p.parseExpr(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(lexical.Semicolon); err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+// Precedence maps each binary operator to how tightly it binds, higher
+// values binding tighter. Tokens missing from it aren't binary operators.
+var Precedence = map[int]int{
+	lexical.Or:             1,
+	lexical.And:            2,
+	lexical.BitOr:          3,
+	lexical.BitXor:         4,
+	lexical.BitAnd:         5,
+	lexical.EqualEqual:     6,
+	lexical.NotEqual:       6,
+	lexical.LessThan:       7,
+	lexical.GreaterThan:    7,
+	lexical.LessOrEqual:    7,
+	lexical.GreaterOrEqual: 7,
+	lexical.ShiftLeft:      8,
+	lexical.ShiftRight:     8,
+	lexical.Plus:           9,
+	lexical.Minus:          9,
+	lexical.Times:          10,
+	lexical.Divide:         10,
+	lexical.Modulo:         10,
+	lexical.Power:          11,
+}
+
+// rightAssociative holds the binary operators grouping from the right,
+// every other one grouping from the left
+var rightAssociative = map[int]bool{
+	lexical.Power: true,
+}
+
+// parseExpr parses E
+func (p *Parser) parseExpr() (Expr, error) {
+	return p.parseBinary(1)
+}
+
+// parseBinary parses an expression whose operators bind at least as tightly
+// as minPrec, by precedence climbing
+func (p *Parser) parseBinary(minPrec int) (Expr, error) {
+	x, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		prec, ok := Precedence[tok.Type]
+		if !ok || prec < minPrec {
+			return x, nil
+		}
+		if _, err = p.next(); err != nil {
+			return nil, err
+		}
+
+		next := prec + 1
+		if rightAssociative[tok.Type] {
+			next = prec
+		}
+		y, err := p.parseBinary(next)
+		if err != nil {
+			return nil, err
+		}
+		x = &BinaryExpr{Position: x.Pos(), Op: tok.Type, X: x, Y: y}
+	}
+}
+
+// parseFactor parses
+//
+//	F = VL | '++' VL | '--' VL | VL '++' | VL '--'
+//	  | '(' E ')'
+//	  | ID '(' LE ')'
+//	  | '-' F | '!' F
+//	  | TRUE | FALSE | CHR | STR | NUM
+func (p *Parser) parseFactor() (Expr, error) {
+	tok, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok.Type {
+	case lexical.PlusPlus, lexical.MinusMinus:
+		if _, err = p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Position: pos(tok), Op: tok.Type, X: x}, nil
+	case lexical.Minus, lexical.Not:
+		if _, err = p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Position: pos(tok), Op: tok.Type, X: x}, nil
+	case lexical.LeftParenthesis:
+		return p.parseCond()
+	case lexical.True, lexical.False, lexical.Character, lexical.Stringval, lexical.Numeral:
+		if _, err = p.next(); err != nil {
+			return nil, err
+		}
+		return &Literal{Position: pos(tok), Token: tok.Type, Value: tok.Secondary}, nil
+	case lexical.ID:
+		return p.parseOperand()
+	}
+
+	return nil, unexpected(tok, "expression")
+}
+
+// parseOperand parses the factors starting with an identifier: calls and
+// values, possibly incremented or decremented
+func (p *Parser) parseOperand() (Expr, error) {
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+
+	_, ok, err := p.accept(lexical.LeftParenthesis)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return p.parseCall(name)
+	}
+
+	x, err := p.parseSelectors(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tok, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if tok.Type == lexical.PlusPlus || tok.Type == lexical.MinusMinus {
+		if _, err = p.next(); err != nil {
+			return nil, err
+		}
+		return &PostfixExpr{Position: x.Pos(), Op: tok.Type, X: x}, nil
+	}
+
+	return x, nil
+}
+
+// parseCall parses the arguments of a call, LE ')', LE = LE ',' E | E
+func (p *Parser) parseCall(name *Ident) (*CallExpr, error) {
+	call := &CallExpr{Position: name.Position, Func: name}
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+
+		_, ok, err := p.accept(lexical.Comma)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+	}
+
+	if _, err := p.expect(lexical.RightParenthesis); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// parseValue parses VL = VL '.' ID | VL '[' E ']' | ID
+func (p *Parser) parseValue() (Expr, error) {
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseSelectors(name)
+}
+
+// parseSelectors parses the field and element accesses following x
+func (p *Parser) parseSelectors(x Expr) (Expr, error) {
+	for {
+		tok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok.Type {
+		case lexical.Dot:
+			if _, err = p.next(); err != nil {
+				return nil, err
+			}
+			field, err := p.parseIdent()
+			if err != nil {
+				return nil, err
+			}
+			x = &FieldExpr{Position: x.Pos(), X: x, Field: field}
+		case lexical.LeftSquare:
+			if _, err = p.next(); err != nil {
+				return nil, err
+			}
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err = p.expect(lexical.RightSquare); err != nil {
+				return nil, err
+			}
+			x = &IndexExpr{Position: x.Pos(), X: x, Index: index}
+		default:
+			return x, nil
+		}
+	}
+}
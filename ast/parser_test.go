@@ -0,0 +1,352 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/lucbarr/sslang/lexical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	program := `
+function main(arg:integer):integer
+{
+	var a:integer;
+	var b:integer;
+	var c:integer;
+	b = 1;
+	c = 2;
+}`
+
+	parsed, err := NewParser(lexical.NewLexer([]byte(program))).Parse()
+	assert.Nil(t, err)
+
+	integer := func(line, column int) *TypeName {
+		return &TypeName{Position: Position{line, column}, Token: lexical.Integer}
+	}
+	expected := &Program{
+		Position: Position{1, 1},
+		Decls: []Decl{
+			&FuncDecl{
+				Position: Position{1, 1},
+				Name:     &Ident{Position: Position{1, 10}, Name: 0},
+				Params: []*Field{
+					{
+						Position: Position{1, 15},
+						Names:    []*Ident{{Position: Position{1, 15}, Name: 1}},
+						Type:     integer(1, 19),
+					},
+				},
+				Result: integer(1, 28),
+				Body: &Block{
+					Position: Position{2, 1},
+					Decls: []*VarDecl{
+						{
+							Position: Position{3, 2},
+							Names:    []*Ident{{Position: Position{3, 6}, Name: 2}},
+							Type:     integer(3, 8),
+						},
+						{
+							Position: Position{4, 2},
+							Names:    []*Ident{{Position: Position{4, 6}, Name: 3}},
+							Type:     integer(4, 8),
+						},
+						{
+							Position: Position{5, 2},
+							Names:    []*Ident{{Position: Position{5, 6}, Name: 4}},
+							Type:     integer(5, 8),
+						},
+					},
+					Stmts: []Stmt{
+						&AssignStmt{
+							Position: Position{6, 2},
+							Target:   &Ident{Position: Position{6, 2}, Name: 3},
+							Value:    &Literal{Position: Position{6, 6}, Token: lexical.Numeral, Value: 0},
+						},
+						&AssignStmt{
+							Position: Position{7, 2},
+							Target:   &Ident{Position: Position{7, 2}, Name: 4},
+							Value:    &Literal{Position: Position{7, 6}, Token: lexical.Numeral, Value: 1},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, expected, parsed)
+}
+
+func TestParseShapes(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		check func(t *testing.T, program *Program)
+	}{
+		"test type declarations": {
+			program: "type A = array [10] of integer\ntype S = struct { x, y: integer; a: A }\ntype I = integer",
+			check: func(t *testing.T, program *Program) {
+				assert.Len(t, program.Decls, 3)
+
+				array := program.Decls[0].(*TypeDecl).Type.(*ArrayType)
+				assert.Equal(t, lexical.Numeral, array.Len.Token)
+				assert.Equal(t, lexical.Integer, array.Elem.(*TypeName).Token)
+
+				fields := program.Decls[1].(*TypeDecl).Type.(*StructType).Fields
+				assert.Len(t, fields, 2)
+				assert.Len(t, fields[0].Names, 2)
+				assert.Equal(t, lexical.ID, fields[1].Type.(*TypeName).Token)
+				assert.Equal(t, 0, fields[1].Type.(*TypeName).Name.Name)
+
+				assert.Equal(t, lexical.Integer, program.Decls[2].(*TypeDecl).Type.(*TypeName).Token)
+			},
+		},
+		"test operators are left associative and bind by level": {
+			program: "var a, b: integer;\nfunction f(x: integer): integer { a = a - b - 1 * x + 2; }",
+			check: func(t *testing.T, program *Program) {
+				value := program.Decls[1].(*FuncDecl).Body.Stmts[0].(*AssignStmt).Value
+
+				plus := value.(*BinaryExpr)
+				assert.Equal(t, lexical.Plus, plus.Op)
+				minus := plus.X.(*BinaryExpr)
+				assert.Equal(t, lexical.Minus, minus.Op)
+				assert.Equal(t, lexical.Times, minus.Y.(*BinaryExpr).Op)
+				assert.Equal(t, lexical.Minus, minus.X.(*BinaryExpr).Op)
+			},
+		},
+		"test statements": {
+			program: `function f(x: boolean): integer {
+	while (x) if (!x) break; else continue;
+	do { x = false; } while (x && true);
+	x = g(x, -1) * a[2].y;
+	x = ++x + x--;
+}`,
+			check: func(t *testing.T, program *Program) {
+				stmts := program.Decls[0].(*FuncDecl).Body.Stmts
+				assert.Len(t, stmts, 4)
+
+				loop := stmts[0].(*WhileStmt)
+				branch := loop.Body.(*IfStmt)
+				assert.Equal(t, lexical.Not, branch.Cond.(*UnaryExpr).Op)
+				assert.IsType(t, &BreakStmt{}, branch.Then)
+				assert.IsType(t, &ContinueStmt{}, branch.Else)
+
+				doWhile := stmts[1].(*DoWhileStmt)
+				assert.Len(t, doWhile.Body.(*Block).Stmts, 1)
+				assert.Equal(t, lexical.And, doWhile.Cond.(*BinaryExpr).Op)
+
+				product := stmts[2].(*AssignStmt).Value.(*BinaryExpr)
+				call := product.X.(*CallExpr)
+				assert.Len(t, call.Args, 2)
+				assert.Equal(t, lexical.Minus, call.Args[1].(*UnaryExpr).Op)
+				field := product.Y.(*FieldExpr)
+				assert.IsType(t, &IndexExpr{}, field.X)
+
+				sum := stmts[3].(*AssignStmt).Value.(*BinaryExpr)
+				assert.Equal(t, lexical.PlusPlus, sum.X.(*UnaryExpr).Op)
+				assert.Equal(t, lexical.MinusMinus, sum.Y.(*PostfixExpr).Op)
+			},
+		},
+		"test empty program": {
+			program: "",
+			check: func(t *testing.T, program *Program) {
+				assert.Empty(t, program.Decls)
+			},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			program, err := NewParser(lexical.NewLexer([]byte(table.program))).Parse()
+			assert.Nil(t, err)
+			table.check(t, program)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		err error
+	}{
+		"test missing semicolon": {
+			program: "var a: integer\nvar b: integer;",
+			err:     &Error{Line: 1, Column: 1, Message: "expected Semicolon, found Var"},
+		},
+		"test missing type": {
+			program: "var a: ;",
+			err:     &Error{Line: 0, Column: 8, Message: "expected type, found Semicolon"},
+		},
+		"test bad statement": {
+			program: "function f(x: integer): integer {\n\tx = 1;\n\t1 = x;\n}",
+			err:     &Error{Line: 2, Column: 2, Message: "expected statement, found Numeral"},
+		},
+		"test lexical error": {
+			program: "var a: integer;\nvar $",
+			err:     &lexical.LexError{Line: 1, Column: 5, Message: "invalid character '$'"},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			program, err := NewParser(lexical.NewLexer([]byte(table.program))).Parse()
+			assert.Nil(t, program)
+			assert.Equal(t, table.err, err)
+		})
+	}
+}
+
+// group renders e fully parenthesized
+func group(lexer *lexical.Lexer, e Expr) string {
+	switch e := e.(type) {
+	case *Ident:
+		name, _ := lexer.IdentifierName(e.Name)
+		return name
+	case *Literal:
+		return fmt.Sprint(lexer.GetNumeralConstant(e.Value))
+	case *UnaryExpr:
+		return "(" + operators[e.Op] + group(lexer, e.X) + ")"
+	case *BinaryExpr:
+		return "(" + group(lexer, e.X) + " " + operators[e.Op] + " " + group(lexer, e.Y) + ")"
+	}
+	return "?"
+}
+
+func TestPrecedence(t *testing.T) {
+	tt := map[string]struct {
+		expr string
+
+		grouped string
+	}{
+		"test multiplication binds tighter than addition": {
+			expr:    "a + b * c",
+			grouped: "(a + (b * c))",
+		},
+		"test left associativity": {
+			expr:    "a - b - c / d / e",
+			grouped: "((a - b) - ((c / d) / e))",
+		},
+		"test comparisons bind looser than arithmetic": {
+			expr:    "a + 1 < b * 2 == c",
+			grouped: "(((a + 1) < (b * 2)) == c)",
+		},
+		"test logical operators": {
+			expr:    "a || b && c == d",
+			grouped: "(a || (b && (c == d)))",
+		},
+		"test parentheses": {
+			expr:    "(a + b) * (c || d)",
+			grouped: "((a + b) * (c || d))",
+		},
+		"test unary operators": {
+			expr:    "-a * b + !c && -(d - e)",
+			grouped: "((((-a) * b) + (!c)) && (-(d - e)))",
+		},
+		"test power is right associative": {
+			expr:    "a ** b ** c * d",
+			grouped: "((a ** (b ** c)) * d)",
+		},
+		"test bitwise operators": {
+			expr:    "a | b ^ c & d << 1 == e",
+			grouped: "(a | (b ^ (c & ((d << 1) == e))))",
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := lexical.NewLexer([]byte("function f(x: integer): integer { x = " + table.expr + "; }"))
+			program, err := NewParser(lexer).Parse()
+			assert.Nil(t, err)
+
+			value := program.Decls[0].(*FuncDecl).Body.Stmts[0].(*AssignStmt).Value
+			assert.Equal(t, table.grouped, group(lexer, value))
+		})
+	}
+}
+
+func TestParseCollectErrors(t *testing.T) {
+	errRead := errors.New("read failed")
+
+	tt := map[string]struct {
+		program string
+		// reader, when set, is read from instead of program
+		reader io.Reader
+
+		errs []error
+		// decls is -1 when parsing stops, leaving no program
+		decls int
+		stmts int
+	}{
+		"test two statements": {
+			program: "function f(x: integer): integer {\n\tx = ;\n\tx = 1;\n\tx = 2 +;\n}",
+			errs: []error{
+				&Error{Line: 1, Column: 6, Message: "expected expression, found Semicolon"},
+				&Error{Line: 3, Column: 9, Message: "expected expression, found Semicolon"},
+			},
+			decls: 1,
+			stmts: 1,
+		},
+		"test two declarations": {
+			program: "var a integer;\ntype = integer\nfunction f(x: integer): integer {\n\tx = 1;\n}",
+			errs: []error{
+				&Error{Line: 0, Column: 7, Message: "expected Colon, found Integer"},
+				&Error{Line: 1, Column: 6, Message: "expected ID, found Equals"},
+			},
+			decls: 1,
+			stmts: 1,
+		},
+		"test lexical and syntax errors": {
+			program: "function f(x: integer): integer {\n\tvar $b: integer;\n\tvar c char;\n\tx = 1;\n}",
+			errs: []error{
+				&lexical.LexError{Line: 1, Column: 6, Message: "invalid character '$'"},
+				&Error{Line: 2, Column: 8, Message: "expected Colon, found Char"},
+			},
+			decls: 1,
+			stmts: 1,
+		},
+		"test unterminated block": {
+			program: "function f(x: integer): integer {\n\tx = 1;",
+			errs: []error{
+				&Error{Line: 1, Column: 8, Message: "expected RightBraces, found EOF"},
+			},
+			decls: 0,
+		},
+		"test read error": {
+			reader: io.MultiReader(strings.NewReader("var x: integer;\n"), iotest.ErrReader(errRead)),
+			errs:   []error{errRead},
+			decls:  -1,
+		},
+		"test no errors": {
+			program: "function f(x: integer): integer {\n\tx = 1;\n}",
+			errs:    []error{},
+			decls:   1,
+			stmts:   1,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := lexical.NewLexer([]byte(table.program))
+			if table.reader != nil {
+				lexer = lexical.NewLexerFromReader(table.reader)
+			}
+
+			program, errs := NewParser(lexer).ParseCollectErrors()
+			assert.Equal(t, table.errs, errs)
+			if table.decls < 0 {
+				assert.Nil(t, program)
+				return
+			}
+			assert.Len(t, program.Decls, table.decls)
+			if table.decls > 0 {
+				assert.Len(t, program.Decls[0].(*FuncDecl).Body.Stmts, table.stmts)
+			}
+		})
+	}
+}
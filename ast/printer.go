@@ -0,0 +1,340 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/lucbarr/sslang/lexical"
+)
+
+// operators maps the tokens of the operators to their text
+var operators = map[int]string{
+	lexical.Or:             "||",
+	lexical.And:            "&&",
+	lexical.BitOr:          "|",
+	lexical.BitXor:         "^",
+	lexical.BitAnd:         "&",
+	lexical.EqualEqual:     "==",
+	lexical.NotEqual:       "!=",
+	lexical.LessThan:       "<",
+	lexical.GreaterThan:    ">",
+	lexical.LessOrEqual:    "<=",
+	lexical.GreaterOrEqual: ">=",
+	lexical.ShiftLeft:      "<<",
+	lexical.ShiftRight:     ">>",
+	lexical.Plus:           "+",
+	lexical.Minus:          "-",
+	lexical.Times:          "*",
+	lexical.Divide:         "/",
+	lexical.Modulo:         "%",
+	lexical.Power:          "**",
+	lexical.Not:            "!",
+	lexical.PlusPlus:       "++",
+	lexical.MinusMinus:     "--",
+}
+
+// keywords maps the tokens of the reserved words that end up in the tree to
+// their text
+var keywords = map[int]string{
+	lexical.Integer: "integer",
+	lexical.Char:    "char",
+	lexical.Boolean: "boolean",
+	lexical.String:  "string",
+	lexical.True:    "true",
+	lexical.False:   "false",
+}
+
+// printer renders a tree back into source
+type printer struct {
+	lexer  *lexical.Lexer
+	sb     strings.Builder
+	indent int
+}
+
+// Print renders n back into source, a tab indenting each level of blocks.
+// Names and literals are spelled by lexer, the one n was parsed from. Only
+// the parentheses precedence requires are kept, so the output parses back
+// to the same tree.
+func Print(lexer *lexical.Lexer, n Node) string {
+	p := &printer{lexer: lexer}
+
+	switch n := n.(type) {
+	case *Program:
+		p.program(n)
+	case Decl:
+		p.decl(n)
+	case Stmt:
+		p.stmt(n)
+	case Expr:
+		p.expr(n)
+	case Type:
+		p.typ(n)
+	}
+
+	return p.sb.String()
+}
+
+func (p *printer) write(s ...string) {
+	for _, str := range s {
+		p.sb.WriteString(str)
+	}
+}
+
+func (p *printer) writeIndent() {
+	p.write(strings.Repeat("\t", p.indent))
+}
+
+func (p *printer) program(program *Program) {
+	for i, decl := range program.Decls {
+		// functions are set apart from their neighbours by a blank line
+		if i > 0 && (isFunc(decl) || isFunc(program.Decls[i-1])) {
+			p.write("\n")
+		}
+		p.decl(decl)
+	}
+}
+
+func isFunc(decl Decl) bool {
+	_, ok := decl.(*FuncDecl)
+	return ok
+}
+
+func (p *printer) decl(decl Decl) {
+	switch decl := decl.(type) {
+	case *FuncDecl:
+		p.write("function ", p.ident(decl.Name), "(")
+		for i, param := range decl.Params {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.field(param)
+		}
+		p.write("): ")
+		p.typ(decl.Result)
+		p.write(" ")
+		p.block(decl.Body)
+		p.write("\n")
+	case *TypeDecl:
+		p.write("type ", p.ident(decl.Name), " = ")
+		p.typ(decl.Type)
+		p.write("\n")
+	case *VarDecl:
+		p.writeIndent()
+		p.write("var ")
+		p.names(decl.Names)
+		p.write(": ")
+		p.typ(decl.Type)
+		p.write(";\n")
+	}
+}
+
+func (p *printer) ident(id *Ident) string {
+	name, _ := p.lexer.IdentifierName(id.Name)
+	return name
+}
+
+func (p *printer) names(names []*Ident) {
+	for i, name := range names {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.write(p.ident(name))
+	}
+}
+
+func (p *printer) field(field *Field) {
+	p.names(field.Names)
+	p.write(": ")
+	p.typ(field.Type)
+}
+
+func (p *printer) typ(t Type) {
+	switch t := t.(type) {
+	case *TypeName:
+		if t.Name != nil {
+			p.write(p.ident(t.Name))
+			return
+		}
+		p.write(keywords[t.Token])
+	case *ArrayType:
+		p.write("array [")
+		p.expr(t.Len)
+		p.write("] of ")
+		p.typ(t.Elem)
+	case *StructType:
+		p.write("struct { ")
+		for i, field := range t.Fields {
+			if i > 0 {
+				p.write("; ")
+			}
+			p.field(field)
+		}
+		p.write(" }")
+	}
+}
+
+// block renders a block from its opening brace to its closing one
+func (p *printer) block(block *Block) {
+	p.write("{\n")
+	p.indent++
+	for _, decl := range block.Decls {
+		p.decl(decl)
+	}
+	for _, stmt := range block.Stmts {
+		p.stmt(stmt)
+	}
+	p.indent--
+	p.writeIndent()
+	p.write("}")
+}
+
+// stmt renders a statement on lines of its own
+func (p *printer) stmt(stmt Stmt) {
+	p.writeIndent()
+	p.inlineStmt(stmt)
+}
+
+// inlineStmt renders a statement from where the current line is at
+func (p *printer) inlineStmt(stmt Stmt) {
+	switch stmt := stmt.(type) {
+	case *Block:
+		p.block(stmt)
+		p.write("\n")
+	case *AssignStmt:
+		p.expr(stmt.Target)
+		p.write(" = ")
+		p.expr(stmt.Value)
+		p.write(";\n")
+	case *IfStmt:
+		p.write("if (")
+		p.expr(stmt.Cond)
+		p.write(")")
+		closed := p.branch(stmt.Then)
+		if stmt.Else == nil {
+			if closed {
+				p.write("\n")
+			}
+			return
+		}
+
+		if closed {
+			p.write(" else")
+		} else {
+			p.writeIndent()
+			p.write("else")
+		}
+		if elseIf, ok := stmt.Else.(*IfStmt); ok {
+			p.write(" ")
+			p.inlineStmt(elseIf)
+		} else if p.branch(stmt.Else) {
+			p.write("\n")
+		}
+	case *WhileStmt:
+		p.write("while (")
+		p.expr(stmt.Cond)
+		p.write(")")
+		if p.branch(stmt.Body) {
+			p.write("\n")
+		}
+	case *DoWhileStmt:
+		p.write("do")
+		if p.branch(stmt.Body) {
+			p.write(" ")
+		} else {
+			p.writeIndent()
+		}
+		p.write("while (")
+		p.expr(stmt.Cond)
+		p.write(");\n")
+	case *BreakStmt:
+		p.write("break;\n")
+	case *ContinueStmt:
+		p.write("continue;\n")
+	}
+}
+
+// branch renders the statement of a control structure, blocks on the line
+// of the structure and anything else indented on lines of its own. It
+// returns true if the line is left open, after a block's closing brace.
+func (p *printer) branch(stmt Stmt) bool {
+	if block, ok := stmt.(*Block); ok {
+		p.write(" ")
+		p.block(block)
+		return true
+	}
+
+	p.write("\n")
+	p.indent++
+	p.stmt(stmt)
+	p.indent--
+	return false
+}
+
+func (p *printer) expr(e Expr) {
+	switch e := e.(type) {
+	case *Ident:
+		p.write(p.ident(e))
+	case *Literal:
+		if text, ok := p.lexer.LiteralText(e.Token, e.Value); ok {
+			p.write(text)
+			return
+		}
+		p.write(keywords[e.Token])
+	case *UnaryExpr:
+		p.write(operators[e.Op])
+		p.operand(e.X)
+	case *PostfixExpr:
+		p.expr(e.X)
+		p.write(operators[e.Op])
+	case *BinaryExpr:
+		prec := Precedence[e.Op]
+		p.side(e.X, prec, rightAssociative[e.Op])
+		p.write(" ", operators[e.Op], " ")
+		p.side(e.Y, prec, !rightAssociative[e.Op])
+	case *CallExpr:
+		p.write(p.ident(e.Func), "(")
+		for i, arg := range e.Args {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expr(arg)
+		}
+		p.write(")")
+	case *IndexExpr:
+		p.expr(e.X)
+		p.write("[")
+		p.expr(e.Index)
+		p.write("]")
+	case *FieldExpr:
+		p.expr(e.X)
+		p.write(".", p.ident(e.Field))
+	}
+}
+
+// operand renders the operand of a prefix operator, parenthesizing binary
+// and prefix expressions so that they neither regroup nor merge with the
+// operator into another token
+func (p *printer) operand(e Expr) {
+	switch e.(type) {
+	case *BinaryExpr, *UnaryExpr:
+		p.write("(")
+		p.expr(e)
+		p.write(")")
+	default:
+		p.expr(e)
+	}
+}
+
+// side renders an operand of a binary operator of precedence prec,
+// parenthesizing it if it binds looser, or as tight when it is on the side
+// the operator doesn't associate to
+func (p *printer) side(e Expr, prec int, against bool) {
+	if b, ok := e.(*BinaryExpr); ok {
+		if Precedence[b.Op] < prec || (Precedence[b.Op] == prec && against) {
+			p.write("(")
+			p.expr(e)
+			p.write(")")
+			return
+		}
+	}
+	p.expr(e)
+}
@@ -0,0 +1,135 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lucbarr/sslang/lexical"
+	"github.com/stretchr/testify/assert"
+)
+
+// stripPositions zeroes the positions of every node reachable from v, so
+// that trees of differently laid out sources compare equal
+func stripPositions(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			stripPositions(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			stripPositions(v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).Type == reflect.TypeOf(Position{}) {
+				v.Field(i).Set(reflect.Zero(v.Field(i).Type()))
+				continue
+			}
+			stripPositions(v.Field(i))
+		}
+	}
+}
+
+func parse(t *testing.T, program string) (*Program, *lexical.Lexer) {
+	lexer := lexical.NewLexer([]byte(program))
+	parsed, err := NewParser(lexer).Parse()
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	return parsed, lexer
+}
+
+func TestPrint(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		printed string
+	}{
+		"test sample program": {
+			program: `
+function main(arg:integer):integer
+{
+	var a:integer;
+	var b:integer;
+	var c:integer;
+	b = 1;
+	c = 2;
+}`,
+			printed: `function main(arg: integer): integer {
+	var a: integer;
+	var b: integer;
+	var c: integer;
+	b = 1;
+	c = 2;
+}
+`,
+		},
+		"test declarations": {
+			program: "type A = array [10] of integer type S = struct { x, y: integer; a: A } var s, t: S; var c: char;" +
+				"function f(x: integer, y: boolean): S { { var z: string; z = \"po\\\"ta\\tto\"; } }",
+			printed: `type A = array [10] of integer
+type S = struct { x, y: integer; a: A }
+var s, t: S;
+var c: char;
+
+function f(x: integer, y: boolean): S {
+	{
+		var z: string;
+		z = "po\"ta\tto";
+	}
+}
+`,
+		},
+		"test statements": {
+			program: `function f(x: integer): integer {
+	if (x < 1) x = 1; else if (x > 2) { x = 2; } else x = 3;
+	while (true) if (x == 0) break;
+	do x = x - 1; while (x > 0);
+	do { continue; } while (!false);
+}`,
+			printed: `function f(x: integer): integer {
+	if (x < 1)
+		x = 1;
+	else if (x > 2) {
+		x = 2;
+	} else
+		x = 3;
+	while (true)
+		if (x == 0)
+			break;
+	do
+		x = x - 1;
+	while (x > 0);
+	do {
+		continue;
+	} while (!false);
+}
+`,
+		},
+		"test expressions": {
+			program: "function f(x: integer): integer { x = (a - (b - c)) * -(-d) + g(s.f[1]--, 'c', '\\n'); x = (a ** b) ** c - -++e; }",
+			printed: `function f(x: integer): integer {
+	x = (a - (b - c)) * -(-d) + g(s.f[1]--, 'c', '\n');
+	x = (a ** b) ** c - -(++e);
+}
+`,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			parsed, lexer := parse(t, table.program)
+
+			printed := Print(lexer, parsed)
+			assert.Equal(t, table.printed, printed)
+
+			reparsed, relexer := parse(t, printed)
+			assert.Equal(t, printed, Print(relexer, reparsed))
+
+			stripPositions(reflect.ValueOf(parsed))
+			stripPositions(reflect.ValueOf(reparsed))
+			assert.Equal(t, parsed, reparsed)
+		})
+	}
+}
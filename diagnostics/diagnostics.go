@@ -0,0 +1,62 @@
+package diagnostics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Diagnostic defines an issue found in a program and where it happened
+type Diagnostic struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("line %d:%d: %s", d.Line, d.Column, d.Message)
+}
+
+// Diagnostics collects the issues found in a program by each analyser, its
+// zero value being ready to use
+type Diagnostics struct {
+	items []Diagnostic
+}
+
+// Add collects an issue found at line and col
+func (d *Diagnostics) Add(line, col int, msg string) {
+	d.items = append(d.items, Diagnostic{
+		Line:    line,
+		Column:  col,
+		Message: msg,
+	})
+}
+
+// HasErrors returns true if any issue was collected
+func (d *Diagnostics) HasErrors() bool {
+	return len(d.items) > 0
+}
+
+// Sorted returns the collected issues sorted by position, issues at the
+// same position keeping the order they were added in
+func (d *Diagnostics) Sorted() []Diagnostic {
+	items := append([]Diagnostic{}, d.items...)
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Line != items[j].Line {
+			return items[i].Line < items[j].Line
+		}
+		return items[i].Column < items[j].Column
+	})
+
+	return items
+}
+
+// Error returns the collected issues sorted by position, one per line
+func (d *Diagnostics) Error() string {
+	lines := []string{}
+	for _, item := range d.Sorted() {
+		lines = append(lines, item.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
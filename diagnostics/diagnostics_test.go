@@ -0,0 +1,27 @@
+package diagnostics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnostics(t *testing.T) {
+	d := &Diagnostics{}
+	assert.False(t, d.HasErrors())
+	assert.Equal(t, "", d.Error())
+
+	d.Add(3, 1, "undefined symbol")
+	d.Add(0, 7, "invalid character '&'")
+	d.Add(3, 1, "type mismatch")
+	d.Add(1, 2, "symbol already defined in this block")
+	d.Add(0, 2, "identifier too long")
+
+	assert.True(t, d.HasErrors())
+	assert.Equal(t, "line 0:2: identifier too long\n"+
+		"line 0:7: invalid character '&'\n"+
+		"line 1:2: symbol already defined in this block\n"+
+		"line 3:1: undefined symbol\n"+
+		"line 3:1: type mismatch", d.Error())
+	assert.Equal(t, Diagnostic{Line: 0, Column: 2, Message: "identifier too long"}, d.Sorted()[0])
+}
@@ -3,26 +3,89 @@ package lexical
 // Lexical analyser implementation, see book @ page 4
 
 import (
+	"bufio"
 	"bytes"
-	"errors"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"iter"
+	"maps"
+	"math/big"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"unicode"
+	"unicode/utf8"
 )
 
 // Lexer analyse if a set of tokens is part of our language and
-// parse its tokens stream
+// parse its tokens stream.
+//
+// Tokens are read one at a time, so a lexer can be driven by several
+// goroutines, each token going to exactly one of them, and queried for
+// identifiers and constants meanwhile. The secondary token is then to be
+// taken from NextTokenFull, PeekToken or the tokens of All, reading the
+// SecondaryToken field being only safe when a single goroutine drives the
+// lexer.
 type Lexer struct {
-	program *bytes.Buffer
+	// mu guards the lexer's state while a token is read and while
+	// identifiers and constants are looked up
+	mu sync.Mutex
+
+	program *reader
+	source  []byte
 
 	identifiers map[string]int
+	names       []string
+
+	reservedWords map[string]int
 
-	constants []Constant
+	constants    []Constant
+	constantPool map[Constant]int
 
-	Line           int
+	Line int
+
+	// SecondaryToken holds the identifier or constant id of the last token
+	// that carried one
 	SecondaryToken int
+
+	// Offset counts the bytes consumed from the program so far
+	Offset int
+
+	// EmitNewlines makes line breaks lex as Newline tokens instead of being
+	// skipped as whitespace
+	EmitNewlines bool
+
+	// ReservedWords replaces ReservedWordTokens as the reserved words of the
+	// language when set, as loaded by LoadReservedWords
+	ReservedWords map[string]int
+
+	// TabWidth is the width tabs are expanded to by Diagnostic,
+	// DefaultTabWidth when 0
+	TabWidth int
+
+	// MaxIdentLen caps the length of identifiers, 0 means unlimited
+	MaxIdentLen int
+
+	// MaxStringLen caps the length of string literals, once escape
+	// sequences are decoded, 0 means unlimited
+	MaxStringLen int
+
+	column int
+
+	// err is the error that terminated Tokens
+	err error
+
+	// peeked holds the token read ahead by PeekToken, if any
+	peeked    *Token
+	peekedErr error
+
+	lexeme      string
+	tokenLine   int
+	tokenColumn int
+	tokenOffset int
 }
 
 // Constant defines a constant type
@@ -31,17 +94,150 @@ type Constant struct {
 	Value interface{}
 }
 
-// NewLexer builds an analyser
+// BigNumeral is the type of the constants of numerals too large for an int.
+// Other constants are typed by the token of their literal or type, but no
+// token stands for these, so it is numbered apart from every token.
+const BigNumeral = -2
+
+// Token defines a lexed token. Secondary holds the identifier or constant
+// id for tokens that carry one and -1 otherwise.
+type Token struct {
+	Type      int
+	Secondary int
+	Text      string
+	Line      int
+	Column    int
+
+	StartOffset int
+	EndOffset   int
+}
+
+// LexError defines a lexical error and where it happened. Line is zero
+// based like the lexer's, Column counts runes starting from one.
+type LexError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *LexError) Error() string {
+	return fmt.Sprintf("line %d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// bom is the UTF-8 byte order mark some editors start files with
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+// NewLexer builds an analyser. A leading byte order mark is skipped, still
+// counting towards Offset.
 func NewLexer(program []byte) *Lexer {
-	programBuffer := bytes.NewBuffer(program)
+	offset := 0
+	if bytes.HasPrefix(program, bom) {
+		program = program[len(bom):]
+		offset = len(bom)
+	}
+
+	a := newLexer(bytes.NewReader(program), constantsHint(len(program)), offset)
+	a.source = program
+	return a
+}
+
+// NewLexerFromReader builds an analyser reading the program from r as it
+// lexes, through a buffer, instead of holding it whole. A leading byte order
+// mark is skipped as NewLexer does. The source isn't kept, so Diagnostic
+// renders messages without their source line.
+func NewLexerFromReader(r io.Reader) *Lexer {
+	br := bufio.NewReader(r)
+
+	offset := 0
+	if prefix, _ := br.Peek(len(bom)); bytes.Equal(prefix, bom) {
+		br.Discard(len(bom))
+		offset = len(bom)
+	}
+
+	return newLexer(br, 0, offset)
+}
+
+// maxConstants bounds the room made for constants up front
+const maxConstants = 1 << 14
+
+// constantsHint guesses how many constants a program of size bytes holds,
+// a literal along with the syntax around it taking a few dozen bytes
+func constantsHint(size int) int {
+	return min(size/32, maxConstants)
+}
+
+// newLexer builds an analyser reading the program from r, offset bytes of
+// it having been skipped already, making room for hint constants
+func newLexer(r io.Reader, hint, offset int) *Lexer {
 	return &Lexer{
-		identifiers: map[string]int{},
-		constants:   []Constant{},
-		program:     programBuffer,
-		Line:        0,
+		identifiers:   map[string]int{},
+		reservedWords: map[string]int{},
+		constants:     make([]Constant, 0, hint),
+		constantPool:  make(map[Constant]int, hint),
+		program:       newReader(r),
+		Line:          0,
+		Offset:        offset,
+	}
+}
+
+// Option configures a Lexer built by NewLexerWith
+type Option func(*Lexer)
+
+// WithEmitNewlines makes line breaks lex as tokens, see Lexer.EmitNewlines
+func WithEmitNewlines() Option {
+	return func(a *Lexer) {
+		a.EmitNewlines = true
 	}
 }
 
+// WithTabWidth sets the width of tabs in diagnostics, see Lexer.TabWidth
+func WithTabWidth(n int) Option {
+	return func(a *Lexer) {
+		a.TabWidth = n
+	}
+}
+
+// WithMaxIdentLen caps the length of identifiers, see Lexer.MaxIdentLen
+func WithMaxIdentLen(n int) Option {
+	return func(a *Lexer) {
+		a.MaxIdentLen = n
+	}
+}
+
+// WithMaxStringLen caps the length of string literals, see
+// Lexer.MaxStringLen
+func WithMaxStringLen(n int) Option {
+	return func(a *Lexer) {
+		a.MaxStringLen = n
+	}
+}
+
+// WithReservedWords replaces the reserved words of the language, see
+// Lexer.ReservedWords
+func WithReservedWords(words map[string]int) Option {
+	return func(a *Lexer) {
+		a.ReservedWords = words
+	}
+}
+
+// NewLexerWith builds an analyser configured by opts
+func NewLexerWith(program []byte, opts ...Option) *Lexer {
+	a := NewLexer(program)
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// NewLexerFromFile builds an analyser for the program in the given file
+func NewLexerFromFile(path string) (*Lexer, error) {
+	program, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewLexer(program), nil
+}
+
 // Run runs the lexical analysis
 func (a *Lexer) Run() ([]int, error) {
 	tokens := []int{}
@@ -59,28 +255,360 @@ func (a *Lexer) Run() ([]int, error) {
 	return tokens, nil
 }
 
+// contextCheckInterval is the number of tokens RunContext lexes between
+// checks of its context
+const contextCheckInterval = 64
+
+// RunContext runs the lexical analysis as Run does, checking ctx every
+// contextCheckInterval tokens and before the first one. It stops as soon as
+// ctx is found done, returning its error.
+func (a *Lexer) RunContext(ctx context.Context) ([]int, error) {
+	tokens := []int{}
+	for {
+		if len(tokens)%contextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		token, err := a.NextToken()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+
+		if token == EOF {
+			break
+		}
+	}
+	return tokens, nil
+}
+
+// RunFunc runs the lexical analysis calling fn for each token, EOF
+// included, instead of collecting them. It stops at the first lexing error
+// or error returned by fn, returning it.
+func (a *Lexer) RunFunc(fn func(Token) error) error {
+	for token, err := range a.All() {
+		if err != nil {
+			return err
+		}
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunStats runs the lexical analysis counting the occurrences of each token
+// type, EOF included. It stops at the first lexing error, returning it.
+func (a *Lexer) RunStats() (map[int]int, error) {
+	stats := map[int]int{}
+	err := a.RunFunc(func(token Token) error {
+		stats[token.Type]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// RunCollectErrors runs the lexical analysis without stopping at lexical
+// errors. Tokens that fail to lex are skipped and their errors collected,
+// lexing resuming right after them.
+func (a *Lexer) RunCollectErrors() ([]int, []error) {
+	tokens := []int{}
+	errs := []error{}
+	for {
+		token, err := a.NextToken()
+		if err != nil {
+			errs = append(errs, err)
+			if _, ok := err.(*LexError); ok {
+				continue
+			}
+			break
+		}
+		tokens = append(tokens, token)
+
+		if token == EOF {
+			break
+		}
+	}
+	return tokens, errs
+}
+
+// LexAll lexes a whole program without stopping at lexical errors, as
+// RunCollectErrors does, returning its tokens, EOF included, the constants
+// they refer to and the errors found
+func LexAll(program []byte) (tokens []Token, constants []Constant, errs []error) {
+	a := NewLexer(program)
+	for {
+		token, err := a.next()
+		if err != nil {
+			errs = append(errs, err)
+			if _, ok := err.(*LexError); ok {
+				continue
+			}
+			break
+		}
+		tokens = append(tokens, token)
+
+		if token.Type == EOF {
+			break
+		}
+	}
+
+	return tokens, append([]Constant{}, a.constants...), errs
+}
+
 // NextToken returns the next token
 func (a *Lexer) NextToken() (int, error) {
+	token, err := a.next()
+	return token.Type, err
+}
+
+// PeekToken returns the next token without consuming it, the next call to
+// read a token returning it again
+func (a *Lexer) PeekToken() (Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.peeked == nil {
+		token, err := a.read()
+		a.peeked = &token
+		a.peekedErr = err
+	}
+	return *a.peeked, a.peekedErr
+}
+
+// NextTokenFull returns the next token along with its secondary token, or
+// -1 for tokens that don't carry one
+func (a *Lexer) NextTokenFull() (token int, secondary int, err error) {
+	t, err := a.next()
+	return t.Type, t.Secondary, err
+}
+
+// All returns an iterator over the remaining tokens. It stops after
+// yielding EOF or the first error.
+func (a *Lexer) All() iter.Seq2[Token, error] {
+	return func(yield func(Token, error) bool) {
+		for {
+			token, err := a.next()
+			if !yield(token, err) || err != nil || token.Type == EOF {
+				return
+			}
+		}
+	}
+}
+
+// Tokens lexes the remaining tokens in a goroutine, sending them over the
+// returned channel. The channel is closed after EOF or the first error,
+// which Err returns once the channel is drained. Cancelling ctx stops the
+// goroutine and closes the channel even if it is no longer read, Err then
+// returning ctx's error.
+func (a *Lexer) Tokens(ctx context.Context) <-chan Token {
+	tokens := make(chan Token)
+
+	go func() {
+		defer close(tokens)
+
+		for token, err := range a.All() {
+			if err != nil {
+				a.setErr(err)
+				return
+			}
+
+			select {
+			case tokens <- token:
+			case <-ctx.Done():
+				a.setErr(ctx.Err())
+				return
+			}
+		}
+	}()
+
+	return tokens
+}
+
+// Err returns the error that terminated Tokens, if any
+func (a *Lexer) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.err
+}
+
+// setErr records the error that terminated Tokens
+func (a *Lexer) setErr(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.err = err
+}
+
+// jsonToken is the JSON representation of a token
+type jsonToken struct {
+	Type   string      `json:"type"`
+	Text   string      `json:"text"`
+	Line   int         `json:"line"`
+	Column int         `json:"column"`
+	Start  int         `json:"start"`
+	End    int         `json:"end"`
+	Value  interface{} `json:"value"`
+}
+
+// TokensJSON lexes the remaining tokens, EOF included, into a JSON array.
+// Literals carry the value of their constant, characters as strings.
+func (a *Lexer) TokensJSON() ([]byte, error) {
+	tokens := []jsonToken{}
+	for token, err := range a.All() {
+		if err != nil {
+			return nil, err
+		}
+
+		t := jsonToken{
+			Type:   TokenName(token.Type),
+			Text:   token.Text,
+			Line:   token.Line,
+			Column: token.Column,
+			Start:  token.StartOffset,
+			End:    token.EndOffset,
+		}
+
+		switch token.Type {
+		case Numeral, Stringval, Character, True, False:
+			c, _ := a.GetConstant(token.Secondary)
+			t.Value = c.Value
+			if r, ok := c.Value.(rune); ok {
+				t.Value = string(r)
+			}
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	return json.Marshal(tokens)
+}
+
+// TokensSExpr lexes the remaining tokens, EOF included, into a line of
+// S-expressions, one per token, as in (ID "foo") (Numeral 42) (Semicolon).
+// Identifiers are given by name and literals by the value of their constant.
+func (a *Lexer) TokensSExpr() (string, error) {
+	atoms := []string{}
+	for token, err := range a.All() {
+		if err != nil {
+			return "", err
+		}
+
+		atom := TokenName(token.Type)
+		if token.Type == ID {
+			name, _ := a.IdentifierName(token.Secondary)
+			atom += " " + quote(name, '"')
+		} else if text, ok := a.LiteralText(token.Type, token.Secondary); ok {
+			atom += " " + text
+		}
+
+		atoms = append(atoms, "("+atom+")")
+	}
+
+	return strings.Join(atoms, " "), nil
+}
+
+// next returns the next token, the one read ahead by PeekToken if any
+func (a *Lexer) next() (Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.peeked == nil {
+		return a.read()
+	}
+
+	token, err := *a.peeked, a.peekedErr
+	a.peeked, a.peekedErr = nil, nil
+	a.lexeme = token.Text
+	if token.Secondary >= 0 {
+		a.SecondaryToken = token.Secondary
+	}
+	return token, err
+}
+
+// read lexes the next token
+func (a *Lexer) read() (Token, error) {
 	token, err := a.nextToken(a.program)
 	if err == io.EOF {
-		token = EOF
+		token, err = EOF, nil
 	}
-	return token, nil
+	if err != nil {
+		return a.token(UNKNOWN), err
+	}
+
+	return a.token(token), nil
 }
 
-func (a *Lexer) nextToken(buf *bytes.Buffer) (int, error) {
+// token builds a Token out of the lexer's current state
+func (a *Lexer) token(t int) Token {
+	secondary := -1
+	switch t {
+	case ID, Numeral, Stringval, Character, True, False:
+		secondary = a.SecondaryToken
+	}
+
+	return Token{
+		Type:      t,
+		Secondary: secondary,
+		Text:      a.lexeme,
+		Line:      a.tokenLine,
+		Column:    a.tokenColumn,
+
+		StartOffset: a.tokenOffset,
+		EndOffset:   a.Offset,
+	}
+}
+
+func (a *Lexer) nextToken(buf *reader) (int, error) {
 	var nextRune, nextRune2 rune
 	var err error
 	token := UNKNOWN
 
+	// whatever gets read since the last mark is the token's source text,
+	// the mark being moved past whitespace as it is skipped
+	start := buf.n
+	buf.mark()
+	defer func() {
+		a.lexeme = string(buf.text)
+		a.tokenOffset = a.Offset + buf.markN - start
+		a.Offset += buf.n - start
+		a.column = advanceColumn(a.tokenColumn-1, a.lexeme)
+	}()
+
+	column := a.column
 	for {
+		buf.mark()
+		a.tokenLine = a.Line
+		a.tokenColumn = column + 1
+
 		nextRune, _, err = buf.ReadRune()
 		if err != nil {
 			return -1, err
 		}
 
-		if nextRune == '\n' {
+		column = advanceColumn(column, string(nextRune))
+
+		if nextRune == '\r' || nextRune == '\n' {
+			// \r\n is a single line break
+			if nextRune == '\r' {
+				nextRune2, _, err = buf.ReadRune()
+				if err == nil && nextRune2 != '\n' {
+					buf.UnreadRune()
+				}
+			}
+
 			a.Line++
+
+			if a.EmitNewlines {
+				return Newline, nil
+			}
 		}
 
 		if !unicode.IsSpace(nextRune) {
@@ -97,7 +625,16 @@ func (a *Lexer) nextToken(buf *bytes.Buffer) (int, error) {
 			return -1, err
 		}
 
-		reservedToken, ok := ReservedWordTokens[text]
+		if a.MaxIdentLen > 0 && utf8.RuneCountInString(text) > a.MaxIdentLen {
+			return -1, a.errorf("identifier too long")
+		}
+
+		reservedToken, ok := a.reservedWords[text]
+		if !ok && a.ReservedWords != nil {
+			reservedToken, ok = a.ReservedWords[text]
+		} else if !ok {
+			reservedToken, ok = ReservedWordTokens[text]
+		}
 		if !ok {
 			a.registerIdentifier(text)
 			token = ID
@@ -105,6 +642,10 @@ func (a *Lexer) nextToken(buf *bytes.Buffer) (int, error) {
 			token = reservedToken
 		}
 
+		if token == True || token == False {
+			a.SecondaryToken = a.addBoolConstant(token == True)
+		}
+
 		buf.UnreadRune()
 
 	} else if isDigit(nextRune) {
@@ -115,20 +656,33 @@ func (a *Lexer) nextToken(buf *bytes.Buffer) (int, error) {
 			return -1, err
 		}
 
-		val, _ := strconv.Atoi(text)
+		if len(text) > 1 && text[0] == '0' {
+			buf.UnreadRune()
+			return -1, a.errorf("invalid numeral with leading zero")
+		}
 
 		token = Numeral
-		a.SecondaryToken = a.addNumeralConstant(val)
+		if val, err := strconv.Atoi(text); err == nil {
+			a.SecondaryToken = a.addNumeralConstant(val)
+		} else if val, ok := new(big.Int).SetString(text, 10); ok {
+			a.SecondaryToken = a.addBigConstant(val)
+		} else {
+			// digits other than ASCII ones have no value
+			buf.UnreadRune()
+			return -1, a.errorf("invalid numeral %s", text)
+		}
 
 		buf.UnreadRune()
 	} else if nextRune == '"' {
-		buf.ReadRune()
-		text, err := parseWord(buf, func(r rune) bool {
-			return r != '"'
-		})
+		text, err := parseString(buf, a.MaxStringLen)
+		if err == io.EOF {
+			return -1, a.errorf("unterminated string")
+		}
+
+		a.Line += countLineBreaks(buf.text)
 
 		if err != nil {
-			return -1, err
+			return -1, a.errorf("%v", err)
 		}
 
 		token = Stringval
@@ -138,6 +692,9 @@ func (a *Lexer) nextToken(buf *bytes.Buffer) (int, error) {
 		case ':':
 			token = Colon
 			break
+		case '?':
+			token = Question
+			break
 		case ';':
 			token = Semicolon
 			break
@@ -145,14 +702,82 @@ func (a *Lexer) nextToken(buf *bytes.Buffer) (int, error) {
 			token = Comma
 			break
 		case '*':
-			token = Times
-			break
+			nextRune2, _, err = buf.ReadRune()
+			if err != nil {
+				if err != io.EOF {
+					return -1, err
+				}
+				token = Times
+				break
+			}
+			if nextRune2 == '*' {
+				token = Power
+			} else if nextRune2 == '=' {
+				token = TimesEq
+			} else {
+				err = buf.UnreadRune()
+				if err != nil {
+					return -1, err
+				}
+				token = Times
+			}
 		case '/':
-			token = Divide
+			nextRune2, _, err = buf.ReadRune()
+			if err != nil {
+				if err != io.EOF {
+					return -1, err
+				}
+				token = Divide
+				break
+			}
+			if nextRune2 != '=' {
+				err = buf.UnreadRune()
+				if err != nil {
+					return -1, err
+				}
+				token = Divide
+			} else {
+				token = DivideEq
+			}
+		case '%':
+			nextRune2, _, err = buf.ReadRune()
+			if err != nil {
+				if err != io.EOF {
+					return -1, err
+				}
+				token = Modulo
+				break
+			}
+			if nextRune2 != '=' {
+				err = buf.UnreadRune()
+				if err != nil {
+					return -1, err
+				}
+				token = Modulo
+			} else {
+				token = ModuloEq
+			}
+		case '^':
+			token = BitXor
 			break
 		case '.':
-			token = Dot
-			break
+			nextRune2, _, err = buf.ReadRune()
+			if err != nil {
+				if err != io.EOF {
+					return -1, err
+				}
+				token = Dot
+				break
+			}
+			if nextRune2 != '.' {
+				err = buf.UnreadRune()
+				if err != nil {
+					return -1, err
+				}
+				token = Dot
+			} else {
+				token = DotDot
+			}
 		case '[':
 			token = LeftSquare
 			break
@@ -173,17 +798,33 @@ func (a *Lexer) nextToken(buf *bytes.Buffer) (int, error) {
 			break
 		case '\'':
 			runeCtt, _, err := buf.ReadRune()
+			if err == io.EOF {
+				return -1, a.errorf("unterminated character")
+			}
 			if err != nil {
 				return -1, err
 			}
 
+			if runeCtt == '\\' {
+				runeCtt, err = parseEscape(buf)
+				if err == io.EOF {
+					return -1, a.errorf("unterminated character")
+				}
+				if err != nil {
+					return -1, a.errorf("%v", err)
+				}
+			}
+
 			expectedQuotes, _, err := buf.ReadRune()
+			if err == io.EOF {
+				return -1, a.errorf("unterminated character")
+			}
 			if err != nil {
 				return -1, err
 			}
 
 			if expectedQuotes != '\'' {
-				return -1, fmt.Errorf("Expected quotes")
+				return -1, a.errorf("expected quotes")
 			}
 
 			token = Character
@@ -192,23 +833,39 @@ func (a *Lexer) nextToken(buf *bytes.Buffer) (int, error) {
 		case '&':
 			nextRune2, _, err = buf.ReadRune()
 			if err != nil {
-				return -1, err
+				if err != io.EOF {
+					return -1, err
+				}
+				token = BitAnd
+				break
 			}
 			if nextRune2 != '&' {
-				return -1, errors.New("Invalid character")
+				err = buf.UnreadRune()
+				if err != nil {
+					return -1, err
+				}
+				token = BitAnd
+			} else {
+				token = And
 			}
-			token = And
-			break
 		case '|':
 			nextRune2, _, err = buf.ReadRune()
 			if err != nil {
-				return -1, err
+				if err != io.EOF {
+					return -1, err
+				}
+				token = BitOr
+				break
 			}
 			if nextRune2 != '|' {
-				return -1, errors.New("Invalid character")
+				err = buf.UnreadRune()
+				if err != nil {
+					return -1, err
+				}
+				token = BitOr
+			} else {
+				token = Or
 			}
-			token = Or
-			break
 		case '=':
 			nextRune2, _, err = buf.ReadRune()
 			if err != nil {
@@ -237,14 +894,16 @@ func (a *Lexer) nextToken(buf *bytes.Buffer) (int, error) {
 				token = LessThan
 				break
 			}
-			if nextRune2 != '=' {
+			if nextRune2 == '=' {
+				token = LessOrEqual
+			} else if nextRune2 == '<' {
+				token = ShiftLeft
+			} else {
 				err = buf.UnreadRune()
 				if err != nil {
 					return -1, err
 				}
 				token = LessThan
-			} else {
-				token = LessOrEqual
 			}
 		case '>':
 			nextRune2, _, err = buf.ReadRune()
@@ -255,14 +914,16 @@ func (a *Lexer) nextToken(buf *bytes.Buffer) (int, error) {
 				token = GreaterThan
 				break
 			}
-			if nextRune2 != '=' {
+			if nextRune2 == '=' {
+				token = GreaterOrEqual
+			} else if nextRune2 == '>' {
+				token = ShiftRight
+			} else {
 				err = buf.UnreadRune()
 				if err != nil {
 					return -1, err
 				}
 				token = GreaterThan
-			} else {
-				token = GreaterOrEqual
 			}
 		case '!':
 			nextRune2, _, err = buf.ReadRune()
@@ -292,14 +953,16 @@ func (a *Lexer) nextToken(buf *bytes.Buffer) (int, error) {
 				token = Plus
 				break
 			}
-			if nextRune2 != '+' {
+			if nextRune2 == '+' {
+				token = PlusPlus
+			} else if nextRune2 == '=' {
+				token = PlusEq
+			} else {
 				err = buf.UnreadRune()
 				if err != nil {
 					return -1, err
 				}
 				token = Plus
-			} else {
-				token = PlusPlus
 			}
 		case '-':
 			nextRune2, _, err = buf.ReadRune()
@@ -311,22 +974,211 @@ func (a *Lexer) nextToken(buf *bytes.Buffer) (int, error) {
 				token = Minus
 				break
 			}
-			if nextRune2 != '-' {
+			if nextRune2 == '-' {
+				token = MinusMinus
+			} else if nextRune2 == '>' {
+				token = Arrow
+			} else if nextRune2 == '=' {
+				token = MinusEq
+			} else {
 				err = buf.UnreadRune()
 				if err != nil {
 					return -1, err
 				}
 				token = Minus
-			} else {
-				token = MinusMinus
 			}
+		default:
+			return -1, a.errorf("invalid character %q", nextRune)
 		}
 	}
 
 	return token, nil
 }
 
-func parseWord(buf *bytes.Buffer, criteria func(rune) bool) (string, error) {
+// errorf builds a LexError positioned at the start of the current token
+func (a *Lexer) errorf(format string, args ...interface{}) error {
+	return &LexError{
+		Line:    a.tokenLine,
+		Column:  a.tokenColumn,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Diagnostic renders err along with the source line it refers to, as
+// FormatDiagnostic does, expanding tabs to TabWidth
+func (a *Lexer) Diagnostic(err *LexError) string {
+	tabWidth := a.TabWidth
+	if tabWidth <= 0 {
+		tabWidth = DefaultTabWidth
+	}
+	return formatDiagnostic(a.source, err.Line, err.Column, err.Message, tabWidth)
+}
+
+// SourceFromTokens rebuilds a program out of tokens lexed by l, one space
+// apart. Identifiers and literals are spelled from l's identifiers and
+// constants, anything else by its lexeme, so the program lexes back to the
+// same tokens.
+func SourceFromTokens(tokens []Token, l *Lexer) string {
+	var sb strings.Builder
+
+	for i, token := range tokens {
+		if token.Type == EOF {
+			continue
+		}
+		text, ok := l.LiteralText(token.Type, token.Secondary)
+		if !ok {
+			text = token.Text
+		}
+
+		if i > 0 && token.Type != Newline && tokens[i-1].Type != Newline {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(text)
+	}
+
+	return sb.String()
+}
+
+// LiteralText spells an identifier or literal given its token type and
+// secondary token, or returns false for any other token
+func (a *Lexer) LiteralText(token, secondary int) (string, bool) {
+	switch token {
+	case ID:
+		return a.IdentifierName(secondary)
+	case Numeral:
+		if val := a.GetBigConstant(secondary); val != nil {
+			return val.String(), true
+		}
+		return "", false
+	case Stringval:
+		return quote(a.GetStringConstant(secondary), '"'), true
+	case Character:
+		return quote(string(a.GetRuneConstant(secondary)), '\''), true
+	}
+
+	return "", false
+}
+
+// quote quotes s between q, escaping what the lexer would otherwise not read
+// back as is
+func quote(s string, q rune) string {
+	var sb strings.Builder
+
+	sb.WriteRune(q)
+	for _, r := range s {
+		switch {
+		case r == q || r == '\\':
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r < ' ' || r == 0x7f:
+			sb.WriteString(fmt.Sprintf(`\x%02x`, r))
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteRune(q)
+
+	return sb.String()
+}
+
+// DefaultTabWidth is the width tabs are expanded to when rendering source
+const DefaultTabWidth = 4
+
+// FormatDiagnostic renders msg along with the source line it refers to and
+// a caret under the column, positioned as LexError's are. Tabs are expanded
+// to DefaultTabWidth.
+func FormatDiagnostic(src []byte, line, col int, msg string) string {
+	return formatDiagnostic(src, line, col, msg, DefaultTabWidth)
+}
+
+func formatDiagnostic(src []byte, line, col int, msg string, tabWidth int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("line %d:%d: %s\n", line, col, msg))
+
+	lines := sourceLines(src)
+	if line < 0 || line >= len(lines) {
+		return sb.String()
+	}
+
+	var text, caret strings.Builder
+	width := 0
+	for i, r := range []rune(lines[line]) {
+		n := 1
+		if r == '\t' {
+			n = tabWidth - width%tabWidth
+			r = ' '
+		}
+		if i < col-1 {
+			caret.WriteString(strings.Repeat(" ", n))
+		}
+		text.WriteString(strings.Repeat(string(r), n))
+		width += n
+	}
+	if n := col - 1 - utf8.RuneCountInString(lines[line]); n > 0 {
+		caret.WriteString(strings.Repeat(" ", n))
+	}
+
+	sb.WriteString(text.String())
+	sb.WriteString("\n")
+	sb.WriteString(caret.String())
+	sb.WriteString("^\n")
+
+	return sb.String()
+}
+
+// OffsetToPosition returns the line and column of the byte at offset in
+// src, positioned as LexError's are. Offsets past the end of src are
+// positioned at its end.
+func OffsetToPosition(src []byte, offset int) (line, col int) {
+	if offset > len(src) {
+		offset = len(src)
+	}
+
+	col = 1
+	for i, r := range string(src[:offset]) {
+		switch {
+		case r == '\n' && i > 0 && src[i-1] == '\r':
+			// \r\n is a single line break
+		case r == '\n' || r == '\r':
+			line++
+			col = 1
+		default:
+			col++
+		}
+	}
+
+	return line, col
+}
+
+// sourceLines splits src into lines, any of \r, \n and \r\n ending one
+func sourceLines(src []byte) []string {
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.Split(text, "\n")
+}
+
+// advanceColumn returns the column reached after reading text from column
+func advanceColumn(column int, text string) int {
+	for _, r := range text {
+		if r == '\n' || r == '\r' {
+			column = 0
+		} else {
+			column++
+		}
+	}
+	return column
+}
+
+// countLineBreaks counts the line breaks in text, \r\n counting as one
+func countLineBreaks(text []byte) int {
+	return bytes.Count(text, []byte{'\n'}) + bytes.Count(text, []byte{'\r'}) - bytes.Count(text, []byte("\r\n"))
+}
+
+func parseWord(buf *reader, criteria func(rune) bool) (string, error) {
 	var sb strings.Builder
 	var err error
 
@@ -356,6 +1208,96 @@ func parseWord(buf *bytes.Buffer, criteria func(rune) bool) (string, error) {
 	return sb.String(), nil
 }
 
+// parseString reads a string literal up to its closing quotes, decoding
+// escape sequences. The opening quotes must have already been read. Reading
+// stops as soon as the literal runs over limit runes, once decoded, 0 meaning
+// unlimited.
+func parseString(buf *reader, limit int) (string, error) {
+	var sb strings.Builder
+
+	for n := 0; ; n++ {
+		r, _, err := buf.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		if r == '"' {
+			return sb.String(), nil
+		}
+
+		if limit > 0 && n == limit {
+			return "", fmt.Errorf("string literal too long")
+		}
+
+		if r == '\\' {
+			r, err = parseEscape(buf)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		sb.WriteRune(r)
+	}
+}
+
+// escapes maps the rune following a backslash to the rune it stands for
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+}
+
+// parseEscape decodes an escape sequence whose backslash has already been
+// read
+func parseEscape(buf *reader) (rune, error) {
+	r, _, err := buf.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+
+	switch r {
+	case 'x':
+		return parseHexEscape(buf, r, 2)
+	case 'u':
+		return parseHexEscape(buf, r, 4)
+	}
+
+	escaped, ok := escapes[r]
+	if !ok {
+		return 0, fmt.Errorf("invalid escape sequence \\%c", r)
+	}
+
+	return escaped, nil
+}
+
+// parseHexEscape decodes the digits hex digits of a \x or \u escape
+// sequence
+func parseHexEscape(buf *reader, kind rune, digits int) (rune, error) {
+	var sb strings.Builder
+
+	for i := 0; i < digits; i++ {
+		r, _, err := buf.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+
+		if !unicode.Is(unicode.ASCII_Hex_Digit, r) {
+			return 0, fmt.Errorf("escape sequence \\%c needs %d hex digits", kind, digits)
+		}
+
+		sb.WriteRune(r)
+	}
+
+	val, _ := strconv.ParseUint(sb.String(), 16, 32)
+	if !utf8.ValidRune(rune(val)) {
+		return 0, fmt.Errorf("invalid escape sequence \\%c%s", kind, sb.String())
+	}
+
+	return rune(val), nil
+}
+
 func isAlpha(r rune) bool {
 	return unicode.IsLetter(r)
 }
@@ -374,57 +1316,200 @@ func (a *Lexer) registerIdentifier(s string) {
 	if !ok {
 		secondaryToken = len(a.identifiers)
 		a.identifiers[s] = secondaryToken
+		a.names = append(a.names, s)
 	}
 
 	a.SecondaryToken = secondaryToken
 }
 
+// GetConstant returns the constant given its id
+func (a *Lexer) GetConstant(n int) (Constant, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if n < 0 || n >= len(a.constants) {
+		return Constant{}, false
+	}
+	return a.constants[n], true
+}
+
+// ConstantType returns the type of the constant given its id. Numerals and
+// characters are typed by their literal tokens, Numeral and Character, or
+// BigNumeral for numerals too large for an int. Strings and booleans are
+// typed by the keywords of their types, String and Boolean, rather than by
+// their Stringval, True and False literals.
+func (a *Lexer) ConstantType(n int) (int, bool) {
+	c, ok := a.GetConstant(n)
+	return c.Type, ok
+}
+
 // GetRuneConstant returns the rune constant given its id
 func (a *Lexer) GetRuneConstant(n int) rune {
-	val, _ := a.constants[n].Value.(rune)
+	c, _ := a.GetConstant(n)
+	val, _ := c.Value.(rune)
 	return val
 }
 
 // GetStringConstant returns the string constant given its id
 func (a *Lexer) GetStringConstant(n int) string {
-	val, _ := a.constants[n].Value.(string)
+	c, _ := a.GetConstant(n)
+	val, _ := c.Value.(string)
 	return val
 }
 
 // GetNumeralConstant returns the int constant given its id
 func (a *Lexer) GetNumeralConstant(n int) int {
-	val, _ := a.constants[n].Value.(int)
+	c, _ := a.GetConstant(n)
+	val, _ := c.Value.(int)
 	return val
 }
 
-// setRuneConstant returns the rune constant given its id
+// GetBigConstant returns the numeral constant given its id, whether it fits
+// an int or not, or nil if it isn't a numeral
+func (a *Lexer) GetBigConstant(n int) *big.Int {
+	c, _ := a.GetConstant(n)
+	switch val := c.Value.(type) {
+	case int:
+		return big.NewInt(int64(val))
+	case *big.Int:
+		return new(big.Int).Set(val)
+	}
+	return nil
+}
+
+// GetBoolConstant returns the boolean constant given its id
+func (a *Lexer) GetBoolConstant(n int) bool {
+	c, _ := a.GetConstant(n)
+	val, _ := c.Value.(bool)
+	return val
+}
+
+// addConstant stores a constant and returns its id, reusing the id of an
+// equal constant if one was already stored
+func (a *Lexer) addConstant(c Constant) int {
+	if n, ok := a.constantPool[c]; ok {
+		return n
+	}
+
+	a.constants = append(a.constants, c)
+	a.constantPool[c] = len(a.constants) - 1
+	return len(a.constants) - 1
+}
+
+// addRuneConstant interns a rune constant and returns its id
 func (a *Lexer) addRuneConstant(n rune) int {
-	a.constants = append(a.constants, Constant{
+	return a.addConstant(Constant{
 		Type:  Character,
 		Value: n,
 	})
-	return len(a.constants) - 1
 }
 
-// addStringConstant returns the string constant given its id
+// addStringConstant stores a string constant and returns its id. Strings
+// are interned, equal strings sharing a single constant.
 func (a *Lexer) addStringConstant(n string) int {
-	a.constants = append(a.constants, Constant{
+	return a.addConstant(Constant{
 		Type:  String,
 		Value: n,
 	})
-	return len(a.constants) - 1
 }
 
-// addNumeralConstant returns the int constant given its id
+// addNumeralConstant interns an int constant and returns its id
 func (a *Lexer) addNumeralConstant(n int) int {
-	a.constants = append(a.constants, Constant{
+	return a.addConstant(Constant{
 		Type:  Numeral,
 		Value: n,
 	})
+}
+
+// addBigConstant stores a numeral constant too large for an int and returns
+// its id. big.Ints are interned by their digits, as equal ones don't compare
+// equal.
+func (a *Lexer) addBigConstant(n *big.Int) int {
+	key := Constant{
+		Type:  BigNumeral,
+		Value: n.String(),
+	}
+	if id, ok := a.constantPool[key]; ok {
+		return id
+	}
+
+	a.constants = append(a.constants, Constant{
+		Type:  BigNumeral,
+		Value: n,
+	})
+	a.constantPool[key] = len(a.constants) - 1
 	return len(a.constants) - 1
 }
 
-// Identifiers retrieves the identifiers
+// addBoolConstant stores a boolean constant and returns its id
+func (a *Lexer) addBoolConstant(n bool) int {
+	return a.addConstant(Constant{
+		Type:  Boolean,
+		Value: n,
+	})
+}
+
+// Identifiers retrieves the identifiers, as a copy that lexing further
+// doesn't change
 func (a *Lexer) Identifiers() map[string]int {
-	return a.identifiers
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return maps.Clone(a.identifiers)
+}
+
+// RegisterReservedWord makes word lex as token on this lexer, taking
+// precedence over ReservedWords and ReservedWordTokens. Registering a word
+// again overrides its previous token. Words must be registered before lexing
+// starts.
+func (a *Lexer) RegisterReservedWord(word string, token int) {
+	a.reservedWords[word] = token
+}
+
+// Lexeme returns the source text of the last token
+func (a *Lexer) Lexeme() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.lexeme
+}
+
+// IdentifierOrder returns the identifiers' names indexed by their ids,
+// that is, in order of first occurrence
+func (a *Lexer) IdentifierOrder() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]string{}, a.names...)
+}
+
+// Identifier defines an identifier and the id the lexer gave it
+type Identifier struct {
+	Name string
+	ID   int
+}
+
+// IdentifierTable returns the identifiers along with their ids, sorted by
+// id. Ids are given in order of first occurrence, so the table is the same
+// for every run over the same program.
+func (a *Lexer) IdentifierTable() []Identifier {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	table := make([]Identifier, 0, len(a.names))
+	for id, name := range a.names {
+		table = append(table, Identifier{Name: name, ID: id})
+	}
+	return table
+}
+
+// IdentifierName returns the name of the identifier given its id
+func (a *Lexer) IdentifierName(id int) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if id < 0 || id >= len(a.names) {
+		return "", false
+	}
+	return a.names[id], true
 }
@@ -2,7 +2,18 @@ package lexical
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -46,8 +57,9 @@ func TestParseWord(t *testing.T) {
 
 	for name, table := range tt {
 		t.Run(name, func(t *testing.T) {
-			table.buf.ReadRune()
-			text, err := parseWord(table.buf, table.criteria)
+			buf := newReader(table.buf)
+			buf.ReadRune()
+			text, err := parseWord(buf, table.criteria)
 
 			assert.Equal(t, table.text, text)
 			assert.Equal(t, table.err, err)
@@ -86,7 +98,7 @@ func TestNextToken(t *testing.T) {
 
 	for name, table := range tt {
 		t.Run(name, func(t *testing.T) {
-			token, err := lexer.nextToken(table.buf)
+			token, err := lexer.nextToken(newReader(table.buf))
 			assert.Equal(t, table.err, err)
 			assert.Equal(t, table.token, token)
 		})
@@ -115,17 +127,159 @@ func TestRun(t *testing.T) {
 			tokens:  []int{Var, ID, Integer, EOF},
 			err:     nil,
 		},
+		"test control flow reserved words": {
+			program: "if x while for else return",
+			tokens:  []int{If, ID, While, For, Else, Return, EOF},
+			err:     nil,
+		},
+		"test null literal": {
+			program: "p = null; nullable = nil; Null",
+			tokens:  []int{ID, Equals, Null, Semicolon, ID, Equals, ID, Semicolon, ID, EOF},
+			err:     nil,
+		},
+		"test reserved word prefixes": {
+			program: "format returned iffy",
+			tokens:  []int{ID, ID, ID, EOF},
+			err:     nil,
+		},
+		"test range": {
+			program: "1..10",
+			tokens:  []int{Numeral, DotDot, Numeral, EOF},
+			err:     nil,
+		},
+		"test field access": {
+			program: "a.b",
+			tokens:  []int{ID, Dot, ID, EOF},
+			err:     nil,
+		},
+		"test dot at eof": {
+			program: "a.",
+			tokens:  []int{ID, Dot, EOF},
+			err:     nil,
+		},
+		"test arrow": {
+			program: "f -> integer",
+			tokens:  []int{ID, Arrow, Integer, EOF},
+			err:     nil,
+		},
+		"test minus minus and minus": {
+			program: "a-- -b -",
+			tokens:  []int{ID, MinusMinus, Minus, ID, Minus, EOF},
+			err:     nil,
+		},
+		"test conditional expression": {
+			program: "a ? b : c",
+			tokens:  []int{ID, Question, ID, Colon, ID, EOF},
+			err:     nil,
+		},
+		"test lone question mark": {
+			program: "?",
+			tokens:  []int{Question, EOF},
+			err:     nil,
+		},
+		"test power": {
+			program: "a ** 2",
+			tokens:  []int{ID, Power, Numeral, EOF},
+			err:     nil,
+		},
+		"test times": {
+			program: "a * b*",
+			tokens:  []int{ID, Times, ID, Times, EOF},
+			err:     nil,
+		},
+		"test modulo": {
+			program: "a % b",
+			tokens:  []int{ID, Modulo, ID, EOF},
+			err:     nil,
+		},
+		"test lone modulo": {
+			program: "%",
+			tokens:  []int{Modulo, EOF},
+			err:     nil,
+		},
+		"test bitwise and logical operators": {
+			program: "a & b && c | d || e",
+			tokens:  []int{ID, BitAnd, ID, And, ID, BitOr, ID, Or, ID, EOF},
+			err:     nil,
+		},
+		"test bitwise operators at eof": {
+			program: "a &",
+			tokens:  []int{ID, BitAnd, EOF},
+			err:     nil,
+		},
+		"test lone bitwise or": {
+			program: "|",
+			tokens:  []int{BitOr, EOF},
+			err:     nil,
+		},
+		"test bitwise operators without spaces": {
+			program: "a&b|c&&d",
+			tokens:  []int{ID, BitAnd, ID, BitOr, ID, And, ID, EOF},
+			err:     nil,
+		},
+		"test bitwise xor": {
+			program: "a ^ b^c",
+			tokens:  []int{ID, BitXor, ID, BitXor, ID, EOF},
+			err:     nil,
+		},
+		"test shifts and comparisons": {
+			program: "a << b >> c <= d >= e < f > g",
+			tokens:  []int{ID, ShiftLeft, ID, ShiftRight, ID, LessOrEqual, ID, GreaterOrEqual, ID, LessThan, ID, GreaterThan, ID, EOF},
+			err:     nil,
+		},
+		"test shifts without spaces": {
+			program: "a<<<b>>>c",
+			tokens:  []int{ID, ShiftLeft, LessThan, ID, ShiftRight, GreaterThan, ID, EOF},
+			err:     nil,
+		},
+		"test comparisons at eof": {
+			program: "a < b >",
+			tokens:  []int{ID, LessThan, ID, GreaterThan, EOF},
+			err:     nil,
+		},
+		"test shifts at eof": {
+			program: "<<",
+			tokens:  []int{ShiftLeft, EOF},
+			err:     nil,
+		},
+		"test compound assignment": {
+			program: "a += 1; b -= 1; c *= 2; d /= 2; e %= 2;",
+			tokens: []int{ID, PlusEq, Numeral, Semicolon, ID, MinusEq, Numeral, Semicolon, ID, TimesEq, Numeral, Semicolon,
+				ID, DivideEq, Numeral, Semicolon, ID, ModuloEq, Numeral, Semicolon, EOF},
+			err: nil,
+		},
+		"test plus lookahead": {
+			program: "a+=b++ +c+++=d+",
+			tokens:  []int{ID, PlusEq, ID, PlusPlus, Plus, ID, PlusPlus, PlusEq, ID, Plus, EOF},
+			err:     nil,
+		},
+		"test minus lookahead": {
+			program: "a-=b-- -c->d-",
+			tokens:  []int{ID, MinusEq, ID, MinusMinus, Minus, ID, Arrow, ID, Minus, EOF},
+			err:     nil,
+		},
+		"test compound operators at eof": {
+			program: "a / b %",
+			tokens:  []int{ID, Divide, ID, Modulo, EOF},
+			err:     nil,
+		},
+		"test compound assignment is not equality": {
+			program: "a + == b",
+			tokens:  []int{ID, Plus, EqualEqual, ID, EOF},
+			err:     nil,
+		},
 		"test char constant": {
 			program: "b = 'a'",
 			tokens:  []int{ID, Equals, Character, EOF},
 			err:     nil,
 		},
 		"test all marginal cases": {
-			program: ": ; , = [ ] { } ( ) && || < > <= >= != == + ++ - -- * / . !",
+			program: ": ; , = [ ] { } ( ) && || < > <= >= != == + ++ - -- * / . ! % & | ^ .. -> ? ** << >> += -= *= /= %=",
 			tokens: []int{Colon, Semicolon, Comma, Equals, LeftSquare, RightSquare, LeftBraces, RightBraces,
 				LeftParenthesis, RightParenthesis, And, Or, LessThan, GreaterThan, LessOrEqual,
 				GreaterOrEqual, NotEqual, EqualEqual, Plus, PlusPlus, Minus, MinusMinus, Times,
-				Divide, Dot, Not, EOF},
+				Divide, Dot, Not, Modulo, BitAnd, BitOr, BitXor, DotDot, Arrow, Question, Power, ShiftLeft, ShiftRight,
+				PlusEq, MinusEq, TimesEq, DivideEq, ModuloEq, EOF},
 			err: nil,
 		},
 		"test sample program": {
@@ -155,3 +309,1855 @@ function main(arg:integer):integer
 		})
 	}
 }
+
+func TestAll(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		tokens []int
+		err    error
+	}{
+		"test sample program": {
+			program: `
+function main(arg:integer):integer
+{
+	var a:integer;
+	var b:integer;
+	var c:integer;
+	b = 1;
+	c = 2;
+}`,
+			tokens: []int{Function, ID, LeftParenthesis, ID, Colon, Integer, RightParenthesis, Colon, Integer, LeftBraces, Var, ID, Colon, Integer, Semicolon, Var, ID, Colon, Integer, Semicolon, Var, ID, Colon, Integer, Semicolon, ID, Equals, Numeral, Semicolon, ID, Equals, Numeral, Semicolon, RightBraces, EOF},
+			err:    nil,
+		},
+		"test empty program": {
+			program: "",
+			tokens:  []int{EOF},
+			err:     nil,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+
+			tokens := []int{}
+			var err error
+			for token, tokenErr := range lexer.All() {
+				tokens = append(tokens, token.Type)
+				err = tokenErr
+			}
+
+			assert.Equal(t, table.tokens, tokens)
+			assert.Equal(t, table.err, err)
+		})
+	}
+}
+
+func TestBoolConstants(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		tokens []int
+		values []bool
+	}{
+		"test true and false": {
+			program: "true false",
+			tokens:  []int{True, False, EOF},
+			values:  []bool{true, false},
+		},
+		"test assignment of boolean": {
+			program: "c = false;",
+			tokens:  []int{ID, Equals, False, Semicolon, EOF},
+			values:  []bool{false},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+
+			tokens := []int{}
+			values := []bool{}
+			for {
+				token, err := lexer.NextToken()
+				assert.Nil(t, err)
+
+				tokens = append(tokens, token)
+				if token == True || token == False {
+					values = append(values, lexer.GetBoolConstant(lexer.SecondaryToken))
+				}
+
+				if token == EOF {
+					break
+				}
+			}
+
+			assert.Equal(t, table.tokens, tokens)
+			assert.Equal(t, table.values, values)
+			assert.NotContains(t, lexer.Identifiers(), "true")
+			assert.NotContains(t, lexer.Identifiers(), "false")
+		})
+	}
+}
+
+func TestIdentifierName(t *testing.T) {
+	lexer := NewLexer([]byte("foo bar foo baz"))
+	_, err := lexer.Run()
+	assert.Nil(t, err)
+
+	tt := map[string]struct {
+		id int
+
+		name string
+		ok   bool
+	}{
+		"test first identifier": {
+			id:   0,
+			name: "foo",
+			ok:   true,
+		},
+		"test last identifier": {
+			id:   2,
+			name: "baz",
+			ok:   true,
+		},
+		"test unknown identifier": {
+			id:   3,
+			name: "",
+			ok:   false,
+		},
+		"test negative identifier": {
+			id:   -1,
+			name: "",
+			ok:   false,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			name, ok := lexer.IdentifierName(table.id)
+
+			assert.Equal(t, table.name, name)
+			assert.Equal(t, table.ok, ok)
+		})
+	}
+}
+
+func TestGetConstant(t *testing.T) {
+	lexer := NewLexer([]byte(`42 "potato" 'c' true`))
+	_, err := lexer.Run()
+	assert.Nil(t, err)
+
+	tt := map[string]struct {
+		n int
+
+		constant Constant
+		ok       bool
+	}{
+		"test numeral constant": {
+			n:        0,
+			constant: Constant{Type: Numeral, Value: 42},
+			ok:       true,
+		},
+		"test string constant": {
+			n:        1,
+			constant: Constant{Type: String, Value: "potato"},
+			ok:       true,
+		},
+		"test rune constant": {
+			n:        2,
+			constant: Constant{Type: Character, Value: 'c'},
+			ok:       true,
+		},
+		"test bool constant": {
+			n:        3,
+			constant: Constant{Type: Boolean, Value: true},
+			ok:       true,
+		},
+		"test out of range constant": {
+			n:        4,
+			constant: Constant{},
+			ok:       false,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			constant, ok := lexer.GetConstant(table.n)
+
+			assert.Equal(t, table.constant, constant)
+			assert.Equal(t, table.ok, ok)
+		})
+	}
+
+	assert.Equal(t, 0, lexer.GetNumeralConstant(4))
+}
+
+func TestBigNumeral(t *testing.T) {
+	lexer := NewLexer([]byte("a = 123456789012345678901234567890; b = 42; c = 123456789012345678901234567890; d = 9223372036854775807;"))
+
+	tokens := []Token{}
+	for token, err := range lexer.All() {
+		assert.Nil(t, err)
+		if token.Type == Numeral {
+			tokens = append(tokens, token)
+		}
+	}
+	assert.Len(t, tokens, 4)
+
+	big30, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	assert.Equal(t, big30, lexer.GetBigConstant(tokens[0].Secondary))
+	constantType, _ := lexer.ConstantType(tokens[0].Secondary)
+	assert.Equal(t, BigNumeral, constantType)
+	assert.Equal(t, "UNKNOWN", TokenName(constantType))
+	assert.Equal(t, 0, lexer.GetNumeralConstant(tokens[0].Secondary))
+
+	// in range literals keep their int constants
+	assert.Equal(t, 42, lexer.GetNumeralConstant(tokens[1].Secondary))
+	assert.Equal(t, big.NewInt(42), lexer.GetBigConstant(tokens[1].Secondary))
+	constantType, _ = lexer.ConstantType(tokens[1].Secondary)
+	assert.Equal(t, Numeral, constantType)
+
+	// equal big literals share a constant
+	assert.Equal(t, tokens[0].Secondary, tokens[2].Secondary)
+
+	assert.Equal(t, math.MaxInt64, lexer.GetNumeralConstant(tokens[3].Secondary))
+
+	// the constant can't be changed through the value returned
+	lexer.GetBigConstant(tokens[0].Secondary).SetInt64(0)
+	assert.Equal(t, big30, lexer.GetBigConstant(tokens[0].Secondary))
+
+	text, ok := lexer.LiteralText(Numeral, tokens[0].Secondary)
+	assert.True(t, ok)
+	assert.Equal(t, "123456789012345678901234567890", text)
+
+	assert.Nil(t, lexer.GetBigConstant(99))
+}
+
+func TestConstantPool(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		secondaryTokens []int
+	}{
+		"test repeated numerals": {
+			program:         "1 1 1",
+			secondaryTokens: []int{0, 0, 0},
+		},
+		"test distinct numerals": {
+			program:         "1 2 1",
+			secondaryTokens: []int{0, 1, 0},
+		},
+		"test repeated runes and strings": {
+			program:         `'a' "a" 'a' "a"`,
+			secondaryTokens: []int{0, 1, 0, 1},
+		},
+		"test repeated strings": {
+			program:         `"potato" "tomato" "potato"`,
+			secondaryTokens: []int{0, 1, 0},
+		},
+		"test strings interned by value": {
+			program:         `"a\n" "a\x0a" "a\u000a"`,
+			secondaryTokens: []int{0, 0, 0},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+
+			secondaryTokens := []int{}
+			for token, err := range lexer.All() {
+				assert.Nil(t, err)
+				if token.Type != EOF {
+					secondaryTokens = append(secondaryTokens, token.Secondary)
+				}
+			}
+
+			assert.Equal(t, table.secondaryTokens, secondaryTokens)
+		})
+	}
+}
+
+func TestLexeme(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		lexemes []string
+	}{
+		"test identifiers and numerals": {
+			program: "potato 1234 potato_2",
+			lexemes: []string{"potato", "1234", "potato_2", ""},
+		},
+		"test multi character operators": {
+			program: "a<=b++ != c--",
+			lexemes: []string{"a", "<=", "b", "++", "!=", "c", "--", ""},
+		},
+		"test single character operators": {
+			program: "a < b + c",
+			lexemes: []string{"a", "<", "b", "+", "c", ""},
+		},
+		"test literals": {
+			program: `x = "potato"; y = 'c';`,
+			lexemes: []string{"x", "=", `"potato"`, ";", "y", "=", "'c'", ";", ""},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+
+			lexemes := []string{}
+			for token, err := range lexer.All() {
+				assert.Nil(t, err)
+				assert.Equal(t, lexer.Lexeme(), token.Text)
+				lexemes = append(lexemes, token.Text)
+			}
+
+			assert.Equal(t, table.lexemes, lexemes)
+		})
+	}
+}
+
+func TestEmitNewlines(t *testing.T) {
+	tt := map[string]struct {
+		program      string
+		emitNewlines bool
+
+		tokens []int
+	}{
+		"test newlines skipped by default": {
+			program:      "a\nb\n",
+			emitNewlines: false,
+			tokens:       []int{ID, ID, EOF},
+		},
+		"test newlines emitted": {
+			program:      "a\nb\n",
+			emitNewlines: true,
+			tokens:       []int{ID, Newline, ID, Newline, EOF},
+		},
+		"test consecutive blank lines": {
+			program:      "a\n\n\nb",
+			emitNewlines: true,
+			tokens:       []int{ID, Newline, Newline, Newline, ID, EOF},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+			lexer.EmitNewlines = table.emitNewlines
+
+			tokens, err := lexer.Run()
+
+			assert.Nil(t, err)
+			assert.Equal(t, table.tokens, tokens)
+		})
+	}
+}
+
+func TestMaxIdentLen(t *testing.T) {
+	tt := map[string]struct {
+		program     string
+		maxIdentLen int
+
+		tokens []int
+		err    error
+	}{
+		"test unlimited": {
+			program:     "foobar",
+			maxIdentLen: 0,
+			tokens:      []int{ID, EOF},
+			err:         nil,
+		},
+		"test within limit": {
+			program:     "foo 123456",
+			maxIdentLen: 4,
+			tokens:      []int{ID, Numeral, EOF},
+			err:         nil,
+		},
+		"test over limit": {
+			program:     "foo\nfoobar",
+			maxIdentLen: 4,
+			tokens:      []int{ID, UNKNOWN},
+			err:         &LexError{Line: 1, Column: 1, Message: "identifier too long"},
+		},
+		"test strings are not limited": {
+			program:     `"foobar"`,
+			maxIdentLen: 4,
+			tokens:      []int{Stringval, EOF},
+			err:         nil,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+			lexer.MaxIdentLen = table.maxIdentLen
+
+			tokens := []int{}
+			var err error
+			for token, tokenErr := range lexer.All() {
+				tokens = append(tokens, token.Type)
+				err = tokenErr
+			}
+
+			assert.Equal(t, table.tokens, tokens)
+			assert.Equal(t, table.err, err)
+		})
+	}
+}
+
+func TestRegisterReservedWord(t *testing.T) {
+	tt := map[string]struct {
+		program string
+		words   map[string]int
+
+		tokens []int
+	}{
+		"test no custom words": {
+			program: "repeat integer",
+			words:   map[string]int{},
+			tokens:  []int{ID, Integer, EOF},
+		},
+		"test custom word": {
+			program: "repeat integer",
+			words:   map[string]int{"repeat": While},
+			tokens:  []int{While, Integer, EOF},
+		},
+		"test custom word overrides global one": {
+			program: "repeat integer",
+			words:   map[string]int{"integer": Char},
+			tokens:  []int{ID, Char, EOF},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+			for word, token := range table.words {
+				lexer.RegisterReservedWord(word, token)
+			}
+
+			tokens, err := lexer.Run()
+
+			assert.Nil(t, err)
+			assert.Equal(t, table.tokens, tokens)
+		})
+	}
+}
+
+func TestNextTokenFull(t *testing.T) {
+	lexer := NewLexer([]byte(`foo = 42; bar = "potato"; foo = 42;`))
+
+	tokens := []int{}
+	secondaryTokens := []int{}
+	for {
+		token, secondary, err := lexer.NextTokenFull()
+		assert.Nil(t, err)
+
+		tokens = append(tokens, token)
+		secondaryTokens = append(secondaryTokens, secondary)
+
+		if token == EOF {
+			break
+		}
+	}
+
+	assert.Equal(t, []int{ID, Equals, Numeral, Semicolon, ID, Equals, Stringval, Semicolon, ID, Equals, Numeral, Semicolon, EOF}, tokens)
+	assert.Equal(t, []int{0, -1, 0, -1, 1, -1, 1, -1, 0, -1, 0, -1, -1}, secondaryTokens)
+}
+
+func TestPeekToken(t *testing.T) {
+	lexer := NewLexer([]byte(`foo = "potato";`))
+
+	peeked, err := lexer.PeekToken()
+	assert.Nil(t, err)
+	again, err := lexer.PeekToken()
+	assert.Nil(t, err)
+	assert.Equal(t, peeked, again)
+	assert.Equal(t, Token{Type: ID, Secondary: 0, Text: "foo", Line: 0, Column: 1, StartOffset: 0, EndOffset: 3}, peeked)
+
+	token, secondary, err := lexer.NextTokenFull()
+	assert.Nil(t, err)
+	assert.Equal(t, ID, token)
+	assert.Equal(t, 0, secondary)
+	assert.Equal(t, "foo", lexer.Lexeme())
+
+	token, err = lexer.NextToken()
+	assert.Nil(t, err)
+	assert.Equal(t, Equals, token)
+
+	peeked, err = lexer.PeekToken()
+	assert.Nil(t, err)
+	assert.Equal(t, Stringval, peeked.Type)
+
+	token, secondary, err = lexer.NextTokenFull()
+	assert.Nil(t, err)
+	assert.Equal(t, Stringval, token)
+	assert.Equal(t, 0, secondary)
+	assert.Equal(t, `"potato"`, lexer.Lexeme())
+}
+
+func TestConcurrentNextToken(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 200; i++ {
+		sb.WriteString(fmt.Sprintf("v%d = %d; s = \"s%d\";\n", i%10, i, i))
+	}
+	lexer := NewLexer([]byte(sb.String()))
+
+	type result struct {
+		token     int
+		secondary int
+	}
+	results := make(chan result)
+	done := make(chan struct{})
+
+	for g := 0; g < 4; g++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				token, secondary, err := lexer.NextTokenFull()
+				assert.Nil(t, err)
+				if token == EOF {
+					return
+				}
+
+				// lookups race with the other goroutines lexing
+				switch token {
+				case ID:
+					name, ok := lexer.IdentifierName(secondary)
+					assert.True(t, ok)
+					assert.Equal(t, secondary, lexer.Identifiers()[name])
+				case Numeral, Stringval:
+					_, ok := lexer.GetConstant(secondary)
+					assert.True(t, ok)
+				}
+				results <- result{token, secondary}
+			}
+		}()
+	}
+
+	go func() {
+		for g := 0; g < 4; g++ {
+			<-done
+		}
+		close(results)
+	}()
+
+	counts := map[int]int{}
+	numerals := map[int]bool{}
+	for r := range results {
+		counts[r.token]++
+		if r.token == Numeral {
+			numerals[lexer.GetNumeralConstant(r.secondary)] = true
+		}
+	}
+
+	assert.Equal(t, 400, counts[ID])
+	assert.Equal(t, 400, counts[Equals])
+	assert.Equal(t, 200, counts[Numeral])
+	assert.Equal(t, 200, counts[Stringval])
+	assert.Equal(t, 400, counts[Semicolon])
+	assert.Len(t, numerals, 200)
+	assert.Len(t, lexer.Identifiers(), 11)
+}
+
+func TestParseString(t *testing.T) {
+	tt := map[string]struct {
+		buf   *bytes.Buffer
+		limit int
+
+		text string
+		err  error
+	}{
+		"test plain string": {
+			buf:  bytes.NewBufferString(`potato" 123`),
+			text: "potato",
+			err:  nil,
+		},
+		"test empty string": {
+			buf:  bytes.NewBufferString(`"`),
+			text: "",
+			err:  nil,
+		},
+		"test escaped quotes": {
+			buf:  bytes.NewBufferString(`a\"b"`),
+			text: `a"b`,
+			err:  nil,
+		},
+		"test only escaped quotes": {
+			buf:  bytes.NewBufferString(`\""`),
+			text: `"`,
+			err:  nil,
+		},
+		"test escaped backslash before closing quotes": {
+			buf:  bytes.NewBufferString(`a\\"`),
+			text: `a\`,
+			err:  nil,
+		},
+		"test newline and tab escapes": {
+			buf:  bytes.NewBufferString(`a\nb\tc"`),
+			text: "a\nb\tc",
+			err:  nil,
+		},
+		"test hex escape": {
+			buf:  bytes.NewBufferString(`\x41\x7e"`),
+			text: "A~",
+			err:  nil,
+		},
+		"test unicode escape": {
+			buf:  bytes.NewBufferString(`\u0041\u00e7\u00E3o"`),
+			text: "Ação",
+			err:  nil,
+		},
+		"test hex escape with too few digits": {
+			buf:  bytes.NewBufferString(`\x4"`),
+			text: "",
+			err:  fmt.Errorf(`escape sequence \x needs 2 hex digits`),
+		},
+		"test unicode escape with invalid digits": {
+			buf:  bytes.NewBufferString(`\u00g1"`),
+			text: "",
+			err:  fmt.Errorf(`escape sequence \u needs 4 hex digits`),
+		},
+		"test unicode escape of a surrogate": {
+			buf:  bytes.NewBufferString(`\ud800"`),
+			text: "",
+			err:  fmt.Errorf(`invalid escape sequence \ud800`),
+		},
+		"test invalid escape": {
+			buf:  bytes.NewBufferString(`a\qb"`),
+			text: "",
+			err:  fmt.Errorf(`invalid escape sequence \q`),
+		},
+		"test unterminated string": {
+			buf:  bytes.NewBufferString(`a\"b`),
+			text: "",
+			err:  io.EOF,
+		},
+		"test within limit": {
+			buf:   bytes.NewBufferString(`a\"b"`),
+			limit: 3,
+			text:  `a"b`,
+			err:   nil,
+		},
+		"test over limit": {
+			buf:   bytes.NewBufferString(`a\"bc"`),
+			limit: 3,
+			text:  "",
+			err:   fmt.Errorf("string literal too long"),
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			text, err := parseString(newReader(table.buf), table.limit)
+
+			assert.Equal(t, table.text, text)
+			assert.Equal(t, table.err, err)
+		})
+	}
+}
+
+func TestEscapedLiterals(t *testing.T) {
+	lexer := NewLexer([]byte(`s = "a\"b"; c = '\'';`))
+
+	tokens, err := lexer.Run()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []int{ID, Equals, Stringval, Semicolon, ID, Equals, Character, Semicolon, EOF}, tokens)
+	assert.Equal(t, `a"b`, lexer.GetStringConstant(0))
+	assert.Equal(t, '\'', lexer.GetRuneConstant(1))
+}
+
+func TestHexEscapedLiterals(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		constant Constant
+		err      error
+	}{
+		"test hex escape in a string": {
+			program:  `"\x41b"`,
+			constant: Constant{Type: String, Value: "Ab"},
+			err:      nil,
+		},
+		"test unicode escape in a string": {
+			program:  `"\u0041"`,
+			constant: Constant{Type: String, Value: "A"},
+			err:      nil,
+		},
+		"test hex escape in a char": {
+			program:  `'\x41'`,
+			constant: Constant{Type: Character, Value: 'A'},
+			err:      nil,
+		},
+		"test unicode escape in a char": {
+			program:  `'\u00e7'`,
+			constant: Constant{Type: Character, Value: 'ç'},
+			err:      nil,
+		},
+		"test invalid escape in a char": {
+			program:  "\n" + `'\xz1'`,
+			constant: Constant{},
+			err:      &LexError{Line: 1, Column: 1, Message: `escape sequence \x needs 2 hex digits`},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+
+			_, err := lexer.Run()
+			constant, _ := lexer.GetConstant(0)
+
+			assert.Equal(t, table.err, err)
+			assert.Equal(t, table.constant, constant)
+		})
+	}
+}
+
+func TestMultiLineString(t *testing.T) {
+	lexer := NewLexer([]byte("s = \"first\nsecond\" foo\n\"a\\nb\" bar"))
+
+	lines := map[string]int{}
+	for token, err := range lexer.All() {
+		assert.Nil(t, err)
+		if token.Type == ID {
+			lines[token.Text] = token.Line
+		}
+	}
+
+	assert.Equal(t, map[string]int{"s": 0, "foo": 1, "bar": 2}, lines)
+	assert.Equal(t, "first\nsecond", lexer.GetStringConstant(0))
+}
+
+func TestOffsets(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		offsets [][2]int
+	}{
+		"test ascii program": {
+			program: "a <= 10;",
+			offsets: [][2]int{{0, 1}, {2, 4}, {5, 7}, {7, 8}, {8, 8}},
+		},
+		"test multi-byte runes": {
+			program: "ação = \"é\"",
+			offsets: [][2]int{{0, 6}, {7, 8}, {9, 13}, {13, 13}},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+
+			offsets := [][2]int{}
+			for token, err := range lexer.All() {
+				assert.Nil(t, err)
+				offsets = append(offsets, [2]int{token.StartOffset, token.EndOffset})
+				assert.Equal(t, token.Text, table.program[token.StartOffset:token.EndOffset])
+			}
+
+			assert.Equal(t, table.offsets, offsets)
+			assert.Equal(t, len(table.program), lexer.Offset)
+		})
+	}
+}
+
+func TestLexError(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		err     error
+		message string
+	}{
+		"test leading zero": {
+			program: "a = b\n  && c 07",
+			err:     &LexError{Line: 1, Column: 8, Message: "invalid numeral with leading zero"},
+			message: "line 1:8: invalid numeral with leading zero",
+		},
+		"test invalid character": {
+			program: "a = b\n  && c $d",
+			err:     &LexError{Line: 1, Column: 8, Message: "invalid character '$'"},
+			message: "line 1:8: invalid character '$'",
+		},
+		"test invalid multi byte character": {
+			program: "a = b € c",
+			err:     &LexError{Line: 0, Column: 7, Message: "invalid character '€'"},
+			message: "line 0:7: invalid character '€'",
+		},
+		"test expected quotes": {
+			program: "c = 'ab'",
+			err:     &LexError{Line: 0, Column: 5, Message: "expected quotes"},
+			message: "line 0:5: expected quotes",
+		},
+		"test unterminated string": {
+			program: "\t\"potato",
+			err:     &LexError{Line: 0, Column: 2, Message: "unterminated string"},
+			message: "line 0:2: unterminated string",
+		},
+		"test unterminated character": {
+			program: "c = 'a",
+			err:     &LexError{Line: 0, Column: 5, Message: "unterminated character"},
+			message: "line 0:5: unterminated character",
+		},
+		"test character quotes at end of input": {
+			program: "c = '",
+			err:     &LexError{Line: 0, Column: 5, Message: "unterminated character"},
+			message: "line 0:5: unterminated character",
+		},
+		"test unterminated character escape": {
+			program: "c = '\\x4",
+			err:     &LexError{Line: 0, Column: 5, Message: "unterminated character"},
+			message: "line 0:5: unterminated character",
+		},
+		"test non ASCII digits": {
+			program: "x = ١٢;",
+			err:     &LexError{Line: 0, Column: 5, Message: "invalid numeral ١٢"},
+			message: "line 0:5: invalid numeral ١٢",
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+
+			_, err := lexer.Run()
+
+			assert.Equal(t, table.err, err)
+			assert.EqualError(t, err, table.message)
+		})
+	}
+}
+
+func TestColumns(t *testing.T) {
+	lexer := NewLexer([]byte("var ação: integer;\n  x = \"a\nb\" y"))
+
+	positions := [][2]int{}
+	for token, err := range lexer.All() {
+		assert.Nil(t, err)
+		positions = append(positions, [2]int{token.Line, token.Column})
+	}
+
+	assert.Equal(t, [][2]int{{0, 1}, {0, 5}, {0, 9}, {0, 11}, {0, 18}, {1, 3}, {1, 5}, {1, 7}, {2, 4}, {2, 5}}, positions)
+}
+
+func TestRunCollectErrors(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		tokens []int
+		errs   []error
+	}{
+		"test no errors": {
+			program: "a = b;",
+			tokens:  []int{ID, Equals, ID, Semicolon, EOF},
+			errs:    []error{},
+		},
+		"test several errors": {
+			program: "a = b & 01;\nd = e | 02;",
+			tokens:  []int{ID, Equals, ID, BitAnd, Semicolon, ID, Equals, ID, BitOr, Semicolon, EOF},
+			errs: []error{
+				&LexError{Line: 0, Column: 9, Message: "invalid numeral with leading zero"},
+				&LexError{Line: 1, Column: 9, Message: "invalid numeral with leading zero"},
+			},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+
+			tokens, errs := lexer.RunCollectErrors()
+
+			assert.Equal(t, table.tokens, tokens)
+			assert.Equal(t, table.errs, errs)
+		})
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		lines        []int
+		emitNewlines bool
+		tokens       []int
+	}{
+		"test lf": {
+			program: "a\nb",
+			lines:   []int{0, 1, 1},
+			tokens:  []int{ID, ID, EOF},
+		},
+		"test crlf": {
+			program: "a\r\nb",
+			lines:   []int{0, 1, 1},
+			tokens:  []int{ID, ID, EOF},
+		},
+		"test lone cr": {
+			program: "a\rb\r\rc",
+			lines:   []int{0, 1, 3, 3},
+			tokens:  []int{ID, ID, ID, EOF},
+		},
+		"test crlf inside strings": {
+			program: "\"a\r\nb\rc\" d",
+			lines:   []int{0, 2, 2},
+			tokens:  []int{Stringval, ID, EOF},
+		},
+		"test crlf emitted as a single newline": {
+			program:      "a\r\n\r\nb",
+			lines:        []int{0, 0, 1, 2, 2},
+			emitNewlines: true,
+			tokens:       []int{ID, Newline, Newline, ID, EOF},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+			lexer.EmitNewlines = table.emitNewlines
+
+			lines := []int{}
+			tokens := []int{}
+			for token, err := range lexer.All() {
+				assert.Nil(t, err)
+				lines = append(lines, token.Line)
+				tokens = append(tokens, token.Type)
+			}
+
+			assert.Equal(t, table.lines, lines)
+			assert.Equal(t, table.tokens, tokens)
+		})
+	}
+}
+
+func TestIdentifierOrder(t *testing.T) {
+	lexer := NewLexer([]byte("zeta = alpha + zeta * mu;"))
+
+	_, err := lexer.Run()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"zeta", "alpha", "mu"}, lexer.IdentifierOrder())
+	for id, name := range lexer.IdentifierOrder() {
+		assert.Equal(t, id, lexer.Identifiers()[name])
+	}
+
+	// the map handed out is a copy
+	delete(lexer.Identifiers(), "zeta")
+	assert.Contains(t, lexer.Identifiers(), "zeta")
+}
+
+func TestIdentifierTable(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		table []Identifier
+	}{
+		"test several identifiers": {
+			program: "var zeta, alpha: integer;\nfunction mu(beta: integer): integer { alpha = zeta + beta * alpha; }",
+			table: []Identifier{
+				{Name: "zeta", ID: 0},
+				{Name: "alpha", ID: 1},
+				{Name: "mu", ID: 2},
+				{Name: "beta", ID: 3},
+			},
+		},
+		"test reserved words aren't identifiers": {
+			program: "if (x) while (true) y = 'c';",
+			table: []Identifier{
+				{Name: "x", ID: 0},
+				{Name: "y", ID: 1},
+			},
+		},
+		"test no identifiers": {
+			program: "1 + 2",
+			table:   []Identifier{},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+			_, err := lexer.Run()
+			assert.Nil(t, err)
+
+			assert.Equal(t, table.table, lexer.IdentifierTable())
+
+			// lexing the same program again gives the same table
+			again := NewLexer([]byte(table.program))
+			_, err = again.Run()
+			assert.Nil(t, err)
+			assert.Equal(t, lexer.IdentifierTable(), again.IdentifierTable())
+		})
+	}
+}
+
+func TestLeadingZeros(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		tokens []int
+		err    error
+	}{
+		"test bare zero": {
+			program: "0",
+			tokens:  []int{Numeral, EOF},
+			err:     nil,
+		},
+		"test leading zeros": {
+			program: "a = 007",
+			tokens:  []int{ID, Equals, UNKNOWN},
+			err:     &LexError{Line: 0, Column: 5, Message: "invalid numeral with leading zero"},
+		},
+		"test hex looking numeral": {
+			program: "0x0",
+			tokens:  []int{Numeral, ID, EOF},
+			err:     nil,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+
+			tokens := []int{}
+			var err error
+			for token, tokenErr := range lexer.All() {
+				tokens = append(tokens, token.Type)
+				err = tokenErr
+			}
+
+			assert.Equal(t, table.tokens, tokens)
+			assert.Equal(t, table.err, err)
+		})
+	}
+}
+
+func TestTokens(t *testing.T) {
+	tt := map[string]struct {
+		program string
+
+		tokens []int
+		err    error
+	}{
+		"test sample statement": {
+			program: "b = 1;",
+			tokens:  []int{ID, Equals, Numeral, Semicolon, EOF},
+			err:     nil,
+		},
+		"test terminating error": {
+			program: "b = c & 01;",
+			tokens:  []int{ID, Equals, ID, BitAnd},
+			err:     &LexError{Line: 0, Column: 9, Message: "invalid numeral with leading zero"},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+
+			tokens := []int{}
+			for token := range lexer.Tokens(context.Background()) {
+				tokens = append(tokens, token.Type)
+			}
+
+			assert.Equal(t, table.tokens, tokens)
+			assert.Equal(t, table.err, lexer.Err())
+		})
+	}
+}
+
+func TestTokensCancel(t *testing.T) {
+	lexer := NewLexer([]byte(strings.Repeat("b = 1;\n", 100)))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	tokens := lexer.Tokens(ctx)
+	assert.Equal(t, ID, (<-tokens).Type)
+	cancel()
+
+	// the channel is closed once the goroutine sees the cancellation
+	for range tokens {
+	}
+	assert.Equal(t, context.Canceled, lexer.Err())
+}
+
+func TestConstantType(t *testing.T) {
+	lexer := NewLexer([]byte(`x = 12; s = "potato"; c = 'p'; b = false; t = true; n = 99999999999999999999;`))
+
+	types := []int{}
+	for token, err := range lexer.All() {
+		assert.Nil(t, err)
+		if token.Type == ID || token.Type == EOF {
+			continue
+		}
+
+		if constantType, ok := lexer.ConstantType(token.Secondary); ok {
+			types = append(types, constantType)
+		}
+	}
+
+	assert.Equal(t, []int{Numeral, String, Character, Boolean, Boolean, BigNumeral}, types)
+
+	_, ok := lexer.ConstantType(6)
+	assert.False(t, ok)
+}
+
+func TestNewLexerFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "program.ssl")
+	err := os.WriteFile(path, []byte("var a : integer;"), 0644)
+	assert.Nil(t, err)
+
+	lexer, err := NewLexerFromFile(path)
+	assert.Nil(t, err)
+
+	tokens, err := lexer.Run()
+	assert.Nil(t, err)
+	assert.Equal(t, []int{Var, ID, Colon, Integer, Semicolon, EOF}, tokens)
+
+	lexer, err = NewLexerFromFile(filepath.Join(t.TempDir(), "missing.ssl"))
+	assert.Nil(t, lexer)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestNewLexerFromReader(t *testing.T) {
+	tt := map[string]struct {
+		program string
+		opts    []Option
+	}{
+		"test sample program": {
+			program: "\nfunction main(arg:integer):integer\n{\n\tvar a:integer;\n\tb = 1;\n}",
+		},
+		"test literals and operators": {
+			program: "s = \"po\\\"ta\nto\"; c = '\\x41'; a <<= b >> 2 ** 3 && !c || d != 01;",
+		},
+		"test newlines": {
+			program: "a = 1;\r\nb = 2;\n\rc",
+			opts:    []Option{WithEmitNewlines()},
+		},
+		"test byte order mark and invalid encodings": {
+			program: "\xEF\xBB\xBFação = \"\xff\"; \xfe",
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			expected := NewLexerWith([]byte(table.program), table.opts...)
+			// reading a byte at a time crosses the buffer at every rune
+			actual := NewLexerFromReader(iotest.OneByteReader(strings.NewReader(table.program)))
+			for _, opt := range table.opts {
+				opt(actual)
+			}
+
+			for {
+				want, wantErr := expected.PeekToken()
+				got, gotErr := actual.PeekToken()
+				assert.Equal(t, want, got)
+				assert.Equal(t, wantErr, gotErr)
+
+				expected.NextToken()
+				actual.NextToken()
+				assert.Equal(t, expected.Lexeme(), actual.Lexeme())
+				if want.Type == EOF {
+					break
+				}
+			}
+			assert.Equal(t, expected.constants, actual.constants)
+			assert.Equal(t, expected.names, actual.names)
+		})
+	}
+}
+
+func TestTokensJSON(t *testing.T) {
+	lexer := NewLexer([]byte("x = 0;\ns = \"hi\"; c = 'p'; b = false;"))
+
+	data, err := lexer.TokensJSON()
+	assert.Nil(t, err)
+
+	var tokens []struct {
+		Type   string      `json:"type"`
+		Text   string      `json:"text"`
+		Line   int         `json:"line"`
+		Column int         `json:"column"`
+		Value  interface{} `json:"value"`
+	}
+	assert.Nil(t, json.Unmarshal(data, &tokens))
+
+	types := []string{}
+	for _, token := range tokens {
+		types = append(types, token.Type)
+	}
+	assert.Equal(t, []string{
+		"ID", "Equals", "Numeral", "Semicolon",
+		"ID", "Equals", "Stringval", "Semicolon",
+		"ID", "Equals", "Character", "Semicolon",
+		"ID", "Equals", "False", "Semicolon",
+		"EOF",
+	}, types)
+
+	assert.Equal(t, "x", tokens[0].Text)
+	assert.Nil(t, tokens[0].Value)
+	assert.Equal(t, float64(0), tokens[2].Value)
+	assert.Equal(t, "hi", tokens[6].Value)
+	assert.Equal(t, 1, tokens[6].Line)
+	assert.Equal(t, 5, tokens[6].Column)
+	assert.Equal(t, "p", tokens[10].Value)
+	assert.Equal(t, false, tokens[14].Value)
+
+	_, err = NewLexer([]byte("a & 01")).TokensJSON()
+	assert.Equal(t, &LexError{Line: 0, Column: 5, Message: "invalid numeral with leading zero"}, err)
+}
+
+func TestTokensSExpr(t *testing.T) {
+	lexer := NewLexer([]byte(`
+function main(arg:integer):integer
+{
+	var a:integer;
+	var b:integer;
+	var c:integer;
+	b = 1;
+	c = 2;
+}`))
+
+	sexpr, err := lexer.TokensSExpr()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(sexpr, `(Function) (ID "main") (LeftParenthesis) (ID "arg") (Colon) (Integer)`))
+	assert.Contains(t, sexpr, `(Var) (ID "a") (Colon) (Integer) (Semicolon)`)
+	assert.Contains(t, sexpr, `(ID "b") (Equals) (Numeral 1) (Semicolon)`)
+	assert.Contains(t, sexpr, `(ID "c") (Equals) (Numeral 2) (Semicolon)`)
+	assert.True(t, strings.HasSuffix(sexpr, "(RightBraces) (EOF)"))
+
+	sexpr, err = NewLexer([]byte(`s = "a \"b\""; c = 'p'; t = true;`)).TokensSExpr()
+	assert.Nil(t, err)
+	assert.Equal(t, `(ID "s") (Equals) (Stringval "a \"b\"") (Semicolon) (ID "c") (Equals) (Character 'p') (Semicolon) (ID "t") (Equals) (True) (Semicolon) (EOF)`, sexpr)
+
+	_, err = NewLexer([]byte("a & 01")).TokensSExpr()
+	assert.Equal(t, &LexError{Line: 0, Column: 5, Message: "invalid numeral with leading zero"}, err)
+}
+
+func TestFormatDiagnostic(t *testing.T) {
+	tt := map[string]struct {
+		src  string
+		line int
+		col  int
+
+		diagnostic string
+	}{
+		"test first line": {
+			src:        "b = c & d;\nc = 1;",
+			line:       0,
+			col:        7,
+			diagnostic: "line 0:7: invalid character '&'\nb = c & d;\n      ^\n",
+		},
+		"test later line": {
+			src:        "a = 1;\r\nb = c & d;\nc = 1;",
+			line:       1,
+			col:        7,
+			diagnostic: "line 1:7: invalid character '&'\nb = c & d;\n      ^\n",
+		},
+		"test tabs": {
+			src:        "\tb =\tc & d;",
+			line:       0,
+			col:        6,
+			diagnostic: "line 0:6: invalid character '&'\n    b = c & d;\n        ^\n",
+		},
+		"test multi byte runes": {
+			src:        "s = \"ãé\" & d;",
+			line:       0,
+			col:        10,
+			diagnostic: "line 0:10: invalid character '&'\ns = \"ãé\" & d;\n         ^\n",
+		},
+		"test past the end of line": {
+			src:        "s = \"abc",
+			line:       0,
+			col:        10,
+			diagnostic: "line 0:10: invalid character '&'\ns = \"abc\n         ^\n",
+		},
+		"test missing line": {
+			src:        "a = 1;",
+			line:       3,
+			col:        1,
+			diagnostic: "line 3:1: invalid character '&'\n",
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			diagnostic := FormatDiagnostic([]byte(table.src), table.line, table.col, "invalid character '&'")
+			assert.Equal(t, table.diagnostic, diagnostic)
+		})
+	}
+}
+
+func TestLexAll(t *testing.T) {
+	tokens, constants, errs := LexAll([]byte("x = 12 & 03;\ns = \"potato\"; y = 12 | true; z = 007;"))
+
+	types := []int{}
+	for _, token := range tokens {
+		types = append(types, token.Type)
+	}
+	assert.Equal(t, []int{
+		ID, Equals, Numeral, BitAnd, Semicolon,
+		ID, Equals, Stringval, Semicolon,
+		ID, Equals, Numeral, BitOr, True, Semicolon,
+		ID, Equals, Semicolon,
+		EOF,
+	}, types)
+	assert.Equal(t, []Constant{
+		{Type: Numeral, Value: 12},
+		{Type: String, Value: "potato"},
+		{Type: Boolean, Value: true},
+	}, constants)
+	assert.Equal(t, []error{
+		&LexError{Line: 0, Column: 10, Message: "invalid numeral with leading zero"},
+		&LexError{Line: 1, Column: 34, Message: "invalid numeral with leading zero"},
+	}, errs)
+
+	tokens, _, errs = LexAll([]byte("a $ b # c"))
+	assert.Len(t, tokens, 4)
+	assert.Equal(t, []error{
+		&LexError{Line: 0, Column: 3, Message: "invalid character '$'"},
+		&LexError{Line: 0, Column: 7, Message: "invalid character '#'"},
+	}, errs)
+}
+
+func TestRunFunc(t *testing.T) {
+	errStop := fmt.Errorf("stop")
+
+	tt := map[string]struct {
+		program string
+		stopAt  int
+
+		count int
+		err   error
+	}{
+		"test every token": {
+			program: "x = 1; y = x;",
+			stopAt:  -1,
+			count:   9,
+			err:     nil,
+		},
+		"test abort": {
+			program: "x = 1; y = x;",
+			stopAt:  Semicolon,
+			count:   4,
+			err:     errStop,
+		},
+		"test lexing error": {
+			program: "x = 1 & 02;",
+			stopAt:  -1,
+			count:   4,
+			err:     &LexError{Line: 0, Column: 9, Message: "invalid numeral with leading zero"},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer([]byte(table.program))
+
+			count := 0
+			err := lexer.RunFunc(func(token Token) error {
+				count++
+				if token.Type == table.stopAt {
+					return errStop
+				}
+				return nil
+			})
+
+			assert.Equal(t, table.count, count)
+			assert.Equal(t, table.err, err)
+		})
+	}
+}
+
+// cancelAfter is a context cancelled once its error has been checked a
+// number of times
+type cancelAfter struct {
+	context.Context
+	checks int
+}
+
+func (c *cancelAfter) Err() error {
+	if c.checks == 0 {
+		return context.Canceled
+	}
+	c.checks--
+	return nil
+}
+
+func TestRunContext(t *testing.T) {
+	program := []byte(strings.Repeat("foo = 42;\n", 1000))
+	expected, err := NewLexer(program).Run()
+	assert.Nil(t, err)
+
+	tt := map[string]struct {
+		ctx context.Context
+
+		tokens []int
+		err    error
+		// lexed is the number of tokens lexed before stopping, EOF aside as
+		// it lexes again once reached
+		lexed int
+	}{
+		"test not cancelled": {
+			ctx:    context.Background(),
+			tokens: expected,
+			err:    nil,
+			lexed:  len(expected) - 1,
+		},
+		"test cancelled before starting": {
+			ctx:    &cancelAfter{Context: context.Background(), checks: 0},
+			tokens: nil,
+			err:    context.Canceled,
+			lexed:  0,
+		},
+		"test cancelled midway": {
+			ctx:    &cancelAfter{Context: context.Background(), checks: 3},
+			tokens: nil,
+			err:    context.Canceled,
+			lexed:  3 * contextCheckInterval,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexer(program)
+			tokens, err := lexer.RunContext(table.ctx)
+			assert.Equal(t, table.tokens, tokens)
+			assert.Equal(t, table.err, err)
+
+			rest, _ := lexer.Run()
+			assert.Equal(t, len(expected)-table.lexed, len(rest))
+		})
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = NewLexer(program).RunContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestRunStats(t *testing.T) {
+	lexer := NewLexer([]byte(`
+function main(arg:integer):integer
+{
+	var a:integer;
+	var b:integer;
+	var c:integer;
+	b = 1;
+	c = 2;
+}`))
+
+	stats, err := lexer.RunStats()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, stats[Semicolon])
+	assert.Equal(t, 7, stats[ID])
+	assert.Equal(t, 5, stats[Integer])
+	assert.Equal(t, 2, stats[Numeral])
+	assert.Equal(t, 1, stats[EOF])
+	assert.Equal(t, 0, stats[If])
+
+	total := 0
+	for _, n := range stats {
+		total += n
+	}
+	assert.Equal(t, 35, total)
+
+	stats, err = NewLexer([]byte("a & 01")).RunStats()
+	assert.Nil(t, stats)
+	assert.Equal(t, &LexError{Line: 0, Column: 5, Message: "invalid numeral with leading zero"}, err)
+}
+
+func TestStringInterning(t *testing.T) {
+	lexer := NewLexer([]byte(`a = "potato"; b = "potato";`))
+
+	secondaryTokens := []int{}
+	for token, err := range lexer.All() {
+		assert.Nil(t, err)
+		if token.Type == Stringval {
+			secondaryTokens = append(secondaryTokens, token.Secondary)
+		}
+	}
+
+	assert.Equal(t, []int{0, 0}, secondaryTokens)
+	assert.Equal(t, "potato", lexer.GetStringConstant(0))
+	_, ok := lexer.GetConstant(1)
+	assert.False(t, ok)
+}
+
+func TestNewLexerWith(t *testing.T) {
+	tt := map[string]struct {
+		program string
+		opts    []Option
+
+		tokens []int
+		err    error
+	}{
+		"test no options": {
+			program: "a = b // c\nd",
+			tokens:  []int{ID, Equals, ID, Divide, Divide, ID, ID, EOF},
+		},
+		"test newlines": {
+			program: "a\r\nc",
+			opts:    []Option{WithEmitNewlines()},
+			tokens:  []int{ID, Newline, ID, EOF},
+		},
+		"test max identifier length": {
+			program: "abc abcd",
+			opts:    []Option{WithMaxIdentLen(3)},
+			err:     &LexError{Line: 0, Column: 5, Message: "identifier too long"},
+		},
+		"test reserved words": {
+			program: "enquanto while",
+			opts:    []Option{WithReservedWords(map[string]int{"enquanto": While})},
+			tokens:  []int{While, ID, EOF},
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexerWith([]byte(table.program), table.opts...)
+
+			tokens, err := lexer.Run()
+
+			assert.Equal(t, table.err, err)
+			if table.err == nil {
+				assert.Equal(t, table.tokens, tokens)
+			}
+		})
+	}
+}
+
+func TestDiagnostic(t *testing.T) {
+	program := []byte("a = 1;\n\tb = c & 01;")
+
+	lexer := NewLexerWith(program, WithTabWidth(2))
+	_, err := lexer.Run()
+	assert.Equal(t, "line 1:10: invalid numeral with leading zero\n  b = c & 01;\n          ^\n", lexer.Diagnostic(err.(*LexError)))
+
+	lexer = NewLexer(program)
+	_, err = lexer.Run()
+	assert.Equal(t, FormatDiagnostic(program, 1, 10, "invalid numeral with leading zero"), lexer.Diagnostic(err.(*LexError)))
+}
+
+func TestOffsetToPosition(t *testing.T) {
+	src := []byte("a = 1;\r\nb = \"ãé\";\rc\n\nd")
+
+	tt := map[string]struct {
+		offset int
+
+		line int
+		col  int
+	}{
+		"test start": {
+			offset: 0,
+			line:   0,
+			col:    1,
+		},
+		"test first line": {
+			offset: 4,
+			line:   0,
+			col:    5,
+		},
+		"test crlf": {
+			offset: 8,
+			line:   1,
+			col:    1,
+		},
+		"test after multi byte runes": {
+			offset: 17,
+			line:   1,
+			col:    8,
+		},
+		"test cr": {
+			offset: 20,
+			line:   2,
+			col:    1,
+		},
+		"test empty line": {
+			offset: 22,
+			line:   3,
+			col:    1,
+		},
+		"test last line": {
+			offset: 23,
+			line:   4,
+			col:    1,
+		},
+		"test past the end": {
+			offset: 100,
+			line:   4,
+			col:    2,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			line, col := OffsetToPosition(src, table.offset)
+			assert.Equal(t, table.line, line)
+			assert.Equal(t, table.col, col)
+		})
+	}
+}
+
+func TestSourceFromTokens(t *testing.T) {
+	tt := map[string]struct {
+		program string
+		opts    []Option
+
+		source string
+	}{
+		"test statements": {
+			program: "var a:integer;\n\tb=a+ +1;c=a++;",
+			source:  "var a : integer ; b = a + + 1 ; c = a ++ ;",
+		},
+		"test literals": {
+			program: `s = "a\x41\tb\"\\";c='\''; d = '\n'; b = true && false;`,
+			source:  `s = "aA\tb\"\\" ; c = '\'' ; d = '\n' ; b = true && false ;`,
+		},
+		"test multi line string": {
+			program: "s = \"a\nb\";",
+			source:  `s = "a\nb" ;`,
+		},
+		"test newlines": {
+			program: "a = 1\nb = 2",
+			opts:    []Option{WithEmitNewlines()},
+			source:  "a = 1\nb = 2",
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexerWith([]byte(table.program), table.opts...)
+			tokens := []Token{}
+			for token, err := range lexer.All() {
+				assert.Nil(t, err)
+				tokens = append(tokens, token)
+			}
+
+			source := SourceFromTokens(tokens, lexer)
+			assert.Equal(t, table.source, source)
+
+			relexer := NewLexerWith([]byte(source), table.opts...)
+			retokens := []Token{}
+			for token, err := range relexer.All() {
+				assert.Nil(t, err)
+				retokens = append(retokens, token)
+			}
+
+			assert.Equal(t, len(tokens), len(retokens))
+			for i := range tokens {
+				assert.Equal(t, tokens[i].Type, retokens[i].Type)
+				assert.Equal(t, tokens[i].Secondary, retokens[i].Secondary)
+			}
+		})
+	}
+}
+
+func TestLiteralText(t *testing.T) {
+	lexer := NewLexer([]byte(`foo 42 "po\"tato\n" '\''`))
+	_, err := lexer.Run()
+	assert.Nil(t, err)
+
+	tt := map[string]struct {
+		token     int
+		secondary int
+
+		text string
+		ok   bool
+	}{
+		"test identifier": {
+			token:     ID,
+			secondary: 0,
+			text:      "foo",
+			ok:        true,
+		},
+		"test numeral": {
+			token:     Numeral,
+			secondary: 0,
+			text:      "42",
+			ok:        true,
+		},
+		"test string": {
+			token:     Stringval,
+			secondary: 1,
+			text:      `"po\"tato\n"`,
+			ok:        true,
+		},
+		"test character": {
+			token:     Character,
+			secondary: 2,
+			text:      `'\''`,
+			ok:        true,
+		},
+		"test other tokens": {
+			token:     Semicolon,
+			secondary: -1,
+			text:      "",
+			ok:        false,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			text, ok := lexer.LiteralText(table.token, table.secondary)
+			assert.Equal(t, table.text, text)
+			assert.Equal(t, table.ok, ok)
+		})
+	}
+}
+
+func TestByteOrderMark(t *testing.T) {
+	program := "\xEF\xBB\xBFvar a: integer;\nb = $"
+	lexer := NewLexer([]byte(program))
+
+	tokens := []Token{}
+	var err error
+	for token, tokenErr := range lexer.All() {
+		tokens = append(tokens, token)
+		err = tokenErr
+	}
+
+	assert.Equal(t, Token{Type: Var, Secondary: -1, Text: "var", Line: 0, Column: 1, StartOffset: 3, EndOffset: 6}, tokens[0])
+	assert.Equal(t, "a", program[tokens[1].StartOffset:tokens[1].EndOffset])
+	assert.Equal(t, &LexError{Line: 1, Column: 5, Message: "invalid character '$'"}, err)
+	assert.Equal(t, "line 1:5: invalid character '$'\nb = $\n    ^\n", lexer.Diagnostic(err.(*LexError)))
+
+	tokensOnly, err := NewLexer([]byte("\xEF\xBB\xBF")).Run()
+	assert.Nil(t, err)
+	assert.Equal(t, []int{EOF}, tokensOnly)
+}
+
+func TestMaxStringLen(t *testing.T) {
+	tt := map[string]struct {
+		program      string
+		maxStringLen int
+
+		tokens []int
+		err    error
+	}{
+		"test unlimited": {
+			program:      `"potato potato"`,
+			maxStringLen: 0,
+			tokens:       []int{Stringval, EOF},
+			err:          nil,
+		},
+		"test within limit": {
+			program:      `"potato" "ação\n"`,
+			maxStringLen: 6,
+			tokens:       []int{Stringval, Stringval, EOF},
+			err:          nil,
+		},
+		"test over limit": {
+			program:      "a\n = \"pot\nato\" b",
+			maxStringLen: 6,
+			tokens:       []int{ID, Equals, UNKNOWN},
+			err:          &LexError{Line: 1, Column: 4, Message: "string literal too long"},
+		},
+		"test unterminated over limit": {
+			program:      "\"potatoes",
+			maxStringLen: 6,
+			tokens:       []int{UNKNOWN},
+			err:          &LexError{Line: 0, Column: 1, Message: "string literal too long"},
+		},
+		"test identifiers are not limited": {
+			program:      "potatoes",
+			maxStringLen: 6,
+			tokens:       []int{ID, EOF},
+			err:          nil,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			lexer := NewLexerWith([]byte(table.program), WithMaxStringLen(table.maxStringLen))
+
+			tokens := []int{}
+			var err error
+			for token, tokenErr := range lexer.All() {
+				tokens = append(tokens, token.Type)
+				err = tokenErr
+			}
+
+			assert.Equal(t, table.tokens, tokens)
+			assert.Equal(t, table.err, err)
+		})
+	}
+}
+
+// largeProgram writes a generated program of about 4MB to a file, returning
+// its path
+func largeProgram(b *testing.B) string {
+	var sb strings.Builder
+	for i := 0; sb.Len() < 4<<20; i++ {
+		fmt.Fprintf(&sb, "function f%d(a: integer): integer {\n\tvar s: string;\n\ts = \"potato %d\";\n\ta = a * %d + 1;\n}\n", i, i, i)
+	}
+
+	path := filepath.Join(b.TempDir(), "large.ssl")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+func BenchmarkLexFile(b *testing.B) {
+	path := largeProgram(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		lexer, err := NewLexerFromFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := lexer.RunFunc(func(Token) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLexReader(b *testing.B) {
+	path := largeProgram(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		f, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := NewLexerFromReader(f).RunFunc(func(Token) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+		f.Close()
+	}
+}
+
+func BenchmarkConstants(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "n = %d; s = \"s%d\"; c = '%c';\n", i+1000, i, rune('a'+i%26))
+	}
+	program := []byte(sb.String())
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := NewLexer(program).RunFunc(func(Token) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSmallProgram(b *testing.B) {
+	program := []byte("x = 1;")
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if err := NewLexer(program).RunFunc(func(Token) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package lexical
+
+import (
+	"bufio"
+	"io"
+	"unicode/utf8"
+)
+
+// reader reads a program rune by rune through a buffer, recording the text
+// read since the last mark so that tokens know their source text
+type reader struct {
+	r *bufio.Reader
+
+	// last is the size of the last rune read, -1 when it can't be unread
+	last int
+	// invalid is set when the last rune read was an invalid encoding, read
+	// as a single byte
+	invalid bool
+
+	// text holds the bytes read since the last mark
+	text []byte
+	// n counts the bytes read so far and markN is what it was at the last
+	// mark
+	n     int
+	markN int
+}
+
+func newReader(r io.Reader) *reader {
+	return &reader{
+		r:    bufio.NewReader(r),
+		last: -1,
+	}
+}
+
+// ReadRune reads the next rune, recording it. Invalid encodings read as
+// utf8.RuneError, a byte at a time, their bytes being recorded as is.
+func (r *reader) ReadRune() (rune, int, error) {
+	r.last = -1
+
+	c, size, err := r.r.ReadRune()
+	if err != nil {
+		return c, size, err
+	}
+
+	r.invalid = c == utf8.RuneError && size == 1
+	if r.invalid {
+		// the byte is read again to record it as it was
+		r.r.UnreadByte()
+		b, _ := r.r.ReadByte()
+		r.text = append(r.text, b)
+	} else {
+		r.text = utf8.AppendRune(r.text, c)
+	}
+
+	r.n += size
+	r.last = size
+	return c, size, nil
+}
+
+// UnreadRune unreads the last rune read, failing if the last read wasn't a
+// successful ReadRune
+func (r *reader) UnreadRune() error {
+	if r.last < 0 {
+		return bufio.ErrInvalidUnreadRune
+	}
+
+	var err error
+	if r.invalid {
+		err = r.r.UnreadByte()
+	} else {
+		err = r.r.UnreadRune()
+	}
+	if err != nil {
+		return err
+	}
+
+	r.text = r.text[:len(r.text)-r.last]
+	r.n -= r.last
+	r.last = -1
+	return nil
+}
+
+// mark starts recording the text read anew
+func (r *reader) mark() {
+	r.text = r.text[:0]
+	r.markN = r.n
+}
@@ -1,5 +1,12 @@
 package lexical
 
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
 // Tokens
 const (
 	// reserved words tokens
@@ -60,6 +67,32 @@ const (
 	EOF
 )
 
+// Tokens the grammar's action table has no columns for. The table indexes
+// terminals and nonterminals contiguously from zero, so these are numbered
+// past both to never alias one of its columns, the parser rejecting them as
+// syntax errors.
+const (
+	Newline = iota + 128
+	DotDot
+	Arrow
+	Question
+	Power
+	Modulo
+	BitAnd
+	BitOr
+	BitXor
+	ShiftLeft
+	ShiftRight
+	PlusEq
+	MinusEq
+	TimesEq
+	DivideEq
+	ModuloEq
+	For
+	Return
+	Null
+)
+
 const UNKNOWN = -1
 
 //TokenToString is a toStr equivalent utility map
@@ -110,14 +143,76 @@ var TokenToString = map[int]string{
 	Dot:              "Dot",
 	Not:              "Not",
 	// regular tokens : "//",
-	Character: "Character",
-	Numeral:   "Numeral",
-	Stringval: "Stringval",
-	ID:        "ID",
+	Character:  "Character",
+	Numeral:    "Numeral",
+	Stringval:  "Stringval",
+	ID:         "ID",
+	EOF:        "EOF",
+	Newline:    "Newline",
+	DotDot:     "DotDot",
+	Arrow:      "Arrow",
+	Question:   "Question",
+	Power:      "Power",
+	Modulo:     "Modulo",
+	BitAnd:     "BitAnd",
+	BitOr:      "BitOr",
+	BitXor:     "BitXor",
+	ShiftLeft:  "ShiftLeft",
+	ShiftRight: "ShiftRight",
+	PlusEq:     "PlusEq",
+	MinusEq:    "MinusEq",
+	TimesEq:    "TimesEq",
+	DivideEq:   "DivideEq",
+	ModuloEq:   "ModuloEq",
+	For:        "For",
+	Return:     "Return",
+	Null:       "Null",
 	// this is not my language bruh : "//",
 	UNKNOWN: "UNKNOWN",
 }
 
+// TokenName returns the name of a token, or "UNKNOWN" for anything that
+// isn't a token
+func TokenName(tok int) string {
+	name, ok := TokenToString[tok]
+	if !ok {
+		return TokenToString[UNKNOWN]
+	}
+	return name
+}
+
+// IsOperator returns true if tok is an operator, assignments included
+func IsOperator(tok int) bool {
+	switch tok {
+	case Equals, And, Or, LessThan, GreaterThan, LessOrEqual, GreaterOrEqual,
+		EqualEqual, NotEqual, Plus, Minus, Times, Divide, PlusPlus, MinusMinus,
+		Not, Dot, DotDot, Arrow, Question, Power, Modulo, BitAnd, BitOr, BitXor,
+		ShiftLeft, ShiftRight, PlusEq, MinusEq, TimesEq, DivideEq, ModuloEq:
+		return true
+	}
+	return false
+}
+
+// IsKeyword returns true if tok is a reserved word other than a literal
+func IsKeyword(tok int) bool {
+	switch tok {
+	case Integer, Char, Boolean, String, Type, Array, Of, Struct, Function,
+		Var, If, Else, While, Do, Break, Continue, For, Return:
+		return true
+	}
+	return false
+}
+
+// IsLiteral returns true if tok is a literal. All of them but Null carry a
+// constant.
+func IsLiteral(tok int) bool {
+	switch tok {
+	case Numeral, Stringval, Character, True, False, Null:
+		return true
+	}
+	return false
+}
+
 // ReservedWordTokens maps reserved words strings into its tokens
 var ReservedWordTokens = map[string]int{
 	"array":    Array,
@@ -135,7 +230,43 @@ var ReservedWordTokens = map[string]int{
 	"struct":   Struct,
 	"true":     True,
 	"false":    False,
+	"for":      For,
+	"return":   Return,
+	"null":     Null,
 	"type":     Type,
 	"var":      Var,
 	"while":    While,
 }
+
+// LoadReservedWords reads a set of reserved words, one "word token" pair per
+// line, tokens being given by their names as in TokenToString. Blank lines
+// are skipped. Errors give the offending line counting from one.
+func LoadReservedWords(r io.Reader) (map[string]int, error) {
+	tokens := map[string]int{}
+	for tok, name := range TokenToString {
+		tokens[name] = tok
+	}
+
+	words := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a word and a token", line)
+		}
+
+		tok, ok := tokens[fields[1]]
+		if !ok || tok == UNKNOWN {
+			return nil, fmt.Errorf("line %d: unknown token %q", line, fields[1])
+		}
+		words[fields[0]] = tok
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
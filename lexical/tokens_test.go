@@ -0,0 +1,192 @@
+package lexical
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenName(t *testing.T) {
+	tt := map[string]struct {
+		token int
+
+		name string
+	}{
+		"test identifier": {
+			token: ID,
+			name:  "ID",
+		},
+		"test numeral": {
+			token: Numeral,
+			name:  "Numeral",
+		},
+		"test semicolon": {
+			token: Semicolon,
+			name:  "Semicolon",
+		},
+		"test eof": {
+			token: EOF,
+			name:  "EOF",
+		},
+		"test unknown": {
+			token: UNKNOWN,
+			name:  "UNKNOWN",
+		},
+		"test out of range": {
+			token: EOF + 1000,
+			name:  "UNKNOWN",
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, table.name, TokenName(table.token))
+		})
+	}
+
+	for token := Integer; token <= EOF; token++ {
+		assert.NotEqual(t, "UNKNOWN", TokenName(token))
+	}
+}
+
+func TestLoadReservedWords(t *testing.T) {
+	tt := map[string]struct {
+		definition string
+
+		words map[string]int
+		err   string
+	}{
+		"test words": {
+			definition: "enquanto While\n\n  inteiro   Integer\nverdadeiro True\n",
+			words:      map[string]int{"enquanto": While, "inteiro": Integer, "verdadeiro": True},
+		},
+		"test empty definition": {
+			definition: "",
+			words:      map[string]int{},
+		},
+		"test missing token": {
+			definition: "enquanto While\ninteiro\n",
+			err:        "line 2: expected a word and a token",
+		},
+		"test unknown token": {
+			definition: "enquanto While\n\ninteiro Potato\n",
+			err:        "line 3: unknown token \"Potato\"",
+		},
+		"test constant type": {
+			definition: "grande BigNumeral\n",
+			err:        "line 1: unknown token \"BigNumeral\"",
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			words, err := LoadReservedWords(strings.NewReader(table.definition))
+			if table.err != "" {
+				assert.EqualError(t, err, table.err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, table.words, words)
+		})
+	}
+}
+
+func TestLexWithLoadedReservedWords(t *testing.T) {
+	words, err := LoadReservedWords(strings.NewReader("enquanto While\nverdadeiro True\n"))
+	assert.Nil(t, err)
+
+	lexer := NewLexer([]byte("enquanto verdadeiro while integer"))
+	lexer.ReservedWords = words
+
+	tokens, err := lexer.Run()
+	assert.Nil(t, err)
+	assert.Equal(t, []int{While, True, ID, ID, EOF}, tokens)
+}
+
+func TestTokenCategories(t *testing.T) {
+	tt := map[string]struct {
+		token int
+
+		operator bool
+		keyword  bool
+		literal  bool
+	}{
+		"test plus": {
+			token:    Plus,
+			operator: true,
+		},
+		"test assignment": {
+			token:    Equals,
+			operator: true,
+		},
+		"test compound": {
+			token:    ModuloEq,
+			operator: true,
+		},
+		"test logical and": {
+			token:    And,
+			operator: true,
+		},
+		"test shift": {
+			token:    ShiftLeft,
+			operator: true,
+		},
+		"test while": {
+			token:   While,
+			keyword: true,
+		},
+		"test return": {
+			token:   Return,
+			keyword: true,
+		},
+		"test integer": {
+			token:   Integer,
+			keyword: true,
+		},
+		"test numeral": {
+			token:   Numeral,
+			literal: true,
+		},
+		"test string": {
+			token:   Stringval,
+			literal: true,
+		},
+		"test character": {
+			token:   Character,
+			literal: true,
+		},
+		"test true": {
+			token:   True,
+			literal: true,
+		},
+		"test null": {
+			token:   Null,
+			literal: true,
+		},
+		"test identifier": {
+			token: ID,
+		},
+		"test semicolon": {
+			token: Semicolon,
+		},
+		"test parenthesis": {
+			token: LeftParenthesis,
+		},
+		"test eof": {
+			token: EOF,
+		},
+		"test unknown token": {
+			token: UNKNOWN,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, table.operator, IsOperator(table.token))
+			assert.Equal(t, table.keyword, IsKeyword(table.token))
+			assert.Equal(t, table.literal, IsLiteral(table.token))
+		})
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"flag"
 	"fmt"
-	"io/ioutil"
 
 	"github.com/lucbarr/sslang/lexical"
 	"github.com/lucbarr/sslang/syntatical"
@@ -28,14 +27,12 @@ usage : ./sslang -out=outputFile.vm example.ssl `)
 
 	file := args[0]
 
-	bytes, err := ioutil.ReadFile(file)
+	lexer, err := lexical.NewLexerFromFile(file)
 	if err != nil {
 		fmt.Printf("Could not read file %v\n", file)
 		return
 	}
 
-	lexer := lexical.NewLexer(bytes)
-
 	parser, err := syntatical.NewParser()
 	if err != nil {
 		fmt.Println(err)
@@ -1,13 +1,23 @@
 package scope
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
 
-const (
-	maxNestLevel = 64
-)
+// ErrRedefinition is returned when defining a symbol twice in a block
+var ErrRedefinition = errors.New("symbol already defined in this block")
+
+// ErrUndefined is returned when a symbol isn't defined in any visible block
+var ErrUndefined = errors.New("undefined symbol")
+
+// ErrAliasCycle is returned when an alias ends up referring to itself
+var ErrAliasCycle = errors.New("alias refers to itself")
+
+// ErrNotForward is returned when completing a symbol that isn't a pending
+// forward declaration
+var ErrNotForward = errors.New("symbol is not a forward declaration")
 
 // Kind defines a kind
 type Kind int
@@ -18,39 +28,88 @@ const (
 	KindParam
 	KindFunction
 	KindField
+	KindConst
 
 	KindArrayType
 	KindStructType
 	KindAliasType
 	KindScalarType
+	KindPointerType
+	KindEnumType
 
 	KindUniversal
 
 	KindUndefined = -1
 )
 
+// kindNames are the names of the kinds
+var kindNames = map[Kind]string{
+	KindVar:         "Var",
+	KindParam:       "Param",
+	KindFunction:    "Function",
+	KindField:       "Field",
+	KindConst:       "Const",
+	KindArrayType:   "ArrayType",
+	KindStructType:  "StructType",
+	KindAliasType:   "AliasType",
+	KindScalarType:  "ScalarType",
+	KindPointerType: "PointerType",
+	KindEnumType:    "EnumType",
+	KindUniversal:   "Universal",
+	KindUndefined:   "Undefined",
+}
+
+// String returns the kind's name
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
 func (k Kind) IsType() bool {
 	return k == KindArrayType ||
 		k == KindStructType ||
 		k == KindAliasType ||
-		k == KindScalarType
+		k == KindScalarType ||
+		k == KindPointerType ||
+		k == KindEnumType
 }
 
+// Names of the scalar types. Identifiers are never negative, so these
+// can't clash with any name defined in a program.
+const (
+	NameInt = -(iota + 1)
+	NameChar
+	NameBool
+	NameString
+	NameUniversal
+	NameVoid
+	NameUnsigned
+)
+
 var (
-	IntObj  = Object{-1, nil, KindScalarType, nil}
+	IntObj  = Object{Name: NameInt, Kind: KindScalarType}
 	PIntObj = &IntObj
 
-	CharObj  = Object{-1, nil, KindScalarType, nil}
+	CharObj  = Object{Name: NameChar, Kind: KindScalarType}
 	PCharObj = &CharObj
 
-	BoolObj  = Object{-1, nil, KindScalarType, nil}
+	BoolObj  = Object{Name: NameBool, Kind: KindScalarType}
 	PBoolObj = &BoolObj
 
-	StringObj  = Object{-1, nil, KindScalarType, nil}
+	StringObj  = Object{Name: NameString, Kind: KindScalarType}
 	PStringObj = &StringObj
 
-	UniversalObj  = Object{-1, nil, KindScalarType, nil}
+	UniversalObj  = Object{Name: NameUniversal, Kind: KindScalarType}
 	PUniversalObj = &UniversalObj
+
+	UnsignedObj  = Object{Name: NameUnsigned, Kind: KindScalarType}
+	PUnsignedObj = &UnsignedObj
+
+	// VoidObj is the return type of functions that return nothing
+	VoidObj  = Object{Name: NameVoid, Kind: KindScalarType}
+	PVoidObj = &VoidObj
 )
 
 // Object defines a scope object
@@ -59,6 +118,18 @@ type Object struct {
 	Next *Object
 	Kind Kind
 
+	// DeclLine and DeclColumn are where the symbol was declared, when
+	// defined through DefineSymbolAt
+	DeclLine   int
+	DeclColumn int
+
+	// Used is set by MarkUsed once the symbol is referred to
+	Used bool
+
+	// Forward is set while the object is a forward declaration that hasn't
+	// been completed
+	Forward bool
+
 	// we use this to mimic the polymorphism the professor uses
 	// on his compiler. shrug
 	T ObjectType
@@ -77,6 +148,9 @@ func (a Function) objType() {}
 func (a Var) objType()      {}
 func (a Param) objType()    {}
 func (a Field) objType()    {}
+func (a Const) objType()    {}
+func (a Pointer) objType()  {}
+func (a Enum) objType()     {}
 
 // Alias defines the alias object type
 type Alias struct {
@@ -94,12 +168,31 @@ type Array struct {
 	Size        int
 }
 
+// Pointer defines the pointer object type
+type Pointer struct {
+	Base *Object
+}
+
+// PointerSize is the size of a pointer, a single slot
+const PointerSize = 1
+
 // Struct defines the struct object type
 type Struct struct {
 	Fields *Object
 	Size   int
 }
 
+// Field returns the struct's field object given its name, or nil if there
+// is no such field
+func (s Struct) Field(name int) *Object {
+	for f := s.Fields; f != nil; f = f.Next {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
 // Function defines the function object type
 type Function struct {
 	PRetType *Object
@@ -126,40 +219,281 @@ type Field struct {
 	Size  int
 }
 
+// Const defines the const object type
+type Const struct {
+	PType *Object
+	Value int
+}
+
+// Enum defines the enum object type. Members are consts of the enum type,
+// valued by their position. They are the symbols defined alongside the enum,
+// chained as in their block, the last one first, so only the first
+// NumMembers objects of the chain are members.
+type Enum struct {
+	Members    *Object
+	NumMembers int
+}
+
+// Member returns the enum's member given its name, or nil if there is no
+// such member
+func (e Enum) Member(name int) *Object {
+	m := e.Members
+	for i := 0; i < e.NumMembers; i++ {
+		if m.Name == name {
+			return m
+		}
+		m = m.Next
+	}
+	return nil
+}
+
+// IsMutable returns true if the object can be assigned to
+func (o *Object) IsMutable() bool {
+	return o.Kind == KindVar ||
+		o.Kind == KindParam ||
+		o.Kind == KindField
+}
+
 // Analyser is the scope analyser
 type Analyser struct {
-	symbolTable [maxNestLevel]*Object
+	symbolTable []*Object
 	level       int
+
+	// unused are the variables of ended blocks never marked as used
+	unused []*Object
 }
 
-// NewBlock opens a new block
+// NewBlock opens a new block, returning its level
 func (a *Analyser) NewBlock() int {
+	a.symbolTable = append(a.blocks(), nil)
 	a.level++
-	a.symbolTable[a.level] = nil
 	return a.level
 }
 
-// EndBlock ends a block
+// EndBlock ends a block, returning the level of the enclosing block. It
+// returns -1 when there is no block to end, the outermost one being kept.
+// Variables of the block never marked as used are reported by Unused.
 func (a *Analyser) EndBlock() int {
+	if a.level == 0 {
+		return -1
+	}
+
+	a.ForEachLocal(func(obj *Object) {
+		if obj.Kind == KindVar && !obj.Used {
+			a.unused = append(a.unused, obj)
+		}
+	})
+
+	a.symbolTable[a.level] = nil
+	a.symbolTable = a.symbolTable[:a.level]
 	a.level--
 	return a.level
 }
 
+// Level returns the level of the current block, the outermost one being 0
+func (a *Analyser) Level() int {
+	return a.level
+}
+
+// Reset drops every symbol defined, leaving the analyser at the outermost
+// block so it can be reused
+func (a *Analyser) Reset() {
+	for i := range a.symbolTable {
+		a.symbolTable[i] = nil
+	}
+	a.symbolTable = a.symbolTable[:0]
+	a.level = 0
+	a.unused = nil
+}
+
+// Snapshot returns a marker of the symbols defined in the current block, to
+// be given to Restore
+func (a *Analyser) Snapshot() int {
+	return count(a.blocks()[a.level])
+}
+
+// Restore drops the symbols defined in the current block since marker was
+// taken by Snapshot. Blocks must be ended back to the level the snapshot was
+// taken at before restoring it.
+func (a *Analyser) Restore(marker int) {
+	blocks := a.blocks()
+	for n := count(blocks[a.level]); n > marker; n-- {
+		blocks[a.level] = blocks[a.level].Next
+	}
+}
+
+// MarkUsed marks a symbol as referred to
+func (a *Analyser) MarkUsed(obj *Object) {
+	obj.Used = true
+}
+
+// Unused returns the variables of the blocks ended so far that were never
+// marked as used, in the order their blocks ended
+func (a *Analyser) Unused() []*Object {
+	return a.unused
+}
+
+// blocks returns the symbol table, which always holds at least the
+// outermost block
+func (a *Analyser) blocks() []*Object {
+	if len(a.symbolTable) == 0 {
+		a.symbolTable = []*Object{nil}
+	}
+	return a.symbolTable
+}
+
 // DefineSymbol defines a symbol given its name
 func (a *Analyser) DefineSymbol(name int) *Object {
+	return a.define(a.level, name)
+}
+
+// DefineSymbolAt defines a symbol given its name and the position of its
+// declaration
+func (a *Analyser) DefineSymbolAt(name, line, col int) *Object {
+	obj := a.DefineSymbol(name)
+	obj.DeclLine = line
+	obj.DeclColumn = col
+	return obj
+}
+
+// DefineConst defines a constant symbol of type t given its name
+func (a *Analyser) DefineConst(name int, t *Object) *Object {
+	obj := a.DefineSymbol(name)
+	obj.Kind = KindConst
+	obj.T = Const{PType: t}
+	return obj
+}
+
+// DeclareForward declares a symbol given its name, to be completed later
+// by Complete once its definition is known
+func (a *Analyser) DeclareForward(name int) *Object {
+	obj := a.DefineSymbol(name)
+	obj.Forward = true
+	return obj
+}
+
+// Complete fills in a forward declaration with its kind and type, failing
+// with ErrNotForward if obj isn't a pending forward declaration. Structs are
+// validated by ValidateStruct first, obj being left pending if they aren't
+// valid.
+func (a *Analyser) Complete(obj *Object, kind Kind, t ObjectType) error {
+	if !obj.Forward {
+		return ErrNotForward
+	}
+
+	if kind == KindStructType {
+		if err := a.ValidateStruct(&Object{Kind: kind, T: t}); err != nil {
+			return err
+		}
+	}
+
+	obj.Kind = kind
+	obj.T = t
+	obj.Forward = false
+	return nil
+}
+
+// PendingForwards returns the forward declarations of the current block that
+// haven't been completed, the most recently declared first
+func (a *Analyser) PendingForwards() []*Object {
+	var pending []*Object
+	a.ForEachLocal(func(obj *Object) {
+		if obj.Forward {
+			pending = append(pending, obj)
+		}
+	})
+
+	return pending
+}
+
+// DefineEnum defines an enum type given its name and its members' names,
+// the members being defined as consts alongside it
+func (a *Analyser) DefineEnum(name int, members []int) *Object {
+	enum := a.DefineSymbol(name)
+	enum.Kind = KindEnumType
+
+	var last *Object
+	for i, member := range members {
+		last = a.DefineSymbol(member)
+		last.Kind = KindConst
+		last.T = Const{PType: enum, Value: i}
+	}
+	enum.T = Enum{Members: last, NumMembers: len(members)}
+
+	return enum
+}
+
+// DefineSymbolChecked defines a symbol given its name, failing with
+// ErrRedefinition if the current block already defines it
+func (a *Analyser) DefineSymbolChecked(name int) (*Object, error) {
+	if a.SearchLocalSymbol(name) != nil {
+		return nil, ErrRedefinition
+	}
+	return a.DefineSymbol(name), nil
+}
+
+// define defines a symbol given its name in the block at level
+func (a *Analyser) define(level, name int) *Object {
 	obj := &Object{}
 
 	obj.Name = name
 	obj.Kind = KindUndefined
-	obj.Next = a.symbolTable[a.level]
-	a.symbolTable[a.level] = obj
+	blocks := a.blocks()
+	obj.Next = blocks[level]
+	blocks[level] = obj
+
+	return obj
+}
+
+// builtins are the scalar types programs refer to by an identifier. The
+// integer, char, boolean and string types are named by reserved words
+// instead, which never lex as identifiers, so they aren't seeded here:
+// parsers map their tokens to PIntObj, PCharObj, PBoolObj and PStringObj, as
+// the ast package's checker does.
+var builtins = []struct {
+	name string
+	obj  *Object
+}{
+	{"unsigned", PUnsignedObj},
+}
+
+// InitBuiltins defines the builtin types in the outermost block as aliases
+// of the scalar types. names maps identifiers to their ids, as the lexer's
+// Identifiers does, builtins missing from it being skipped. Scalar types
+// named by reserved words aren't defined, see builtins.
+func (a *Analyser) InitBuiltins(names map[string]int) {
+	for _, builtin := range builtins {
+		name, ok := names[builtin.name]
+		if !ok {
+			continue
+		}
+
+		obj := a.define(0, name)
+		obj.Kind = KindAliasType
+		obj.T = Alias{
+			BaseType: builtin.obj,
+			Size:     a.SizeOf(builtin.obj),
+		}
+	}
+}
+
+// RegisterBuiltinFunc defines a builtin function in the outermost block
+// given its name, parameters and return type
+func (a *Analyser) RegisterBuiltinFunc(name int, params *Object, ret *Object) *Object {
+	obj := a.define(0, name)
+	obj.Kind = KindFunction
+	obj.T = Function{
+		PRetType: ret,
+		PParams:  params,
+		Params:   count(params),
+	}
 
 	return obj
 }
 
 // SearchLocalSymbol searches for a symbol locally
 func (a *Analyser) SearchLocalSymbol(name int) *Object {
-	obj := a.symbolTable[a.level]
+	obj := a.blocks()[a.level]
 
 	for obj != nil {
 
@@ -173,90 +507,553 @@ func (a *Analyser) SearchLocalSymbol(name int) *Object {
 	return obj
 }
 
+// LocalSymbols returns the symbols defined in the current block, the most
+// recently defined first
+func (a *Analyser) LocalSymbols() []*Object {
+	var symbols []*Object
+	a.ForEachLocal(func(obj *Object) {
+		symbols = append(symbols, obj)
+	})
+
+	return symbols
+}
+
+// ForEachLocal calls f for each symbol defined in the current block, the
+// most recently defined first
+func (a *Analyser) ForEachLocal(f func(*Object)) {
+	for obj := a.blocks()[a.level]; obj != nil; obj = obj.Next {
+		f(obj)
+	}
+}
+
+// SymbolCount returns the number of symbols defined in the block at level,
+// 0 for levels that aren't open
+func (a *Analyser) SymbolCount(level int) int {
+	if level < 0 || level > a.level {
+		return 0
+	}
+	return count(a.blocks()[level])
+}
+
 // SearchGlobalSymbol searches for a symbol globally
 func (a *Analyser) SearchGlobalSymbol(name int) *Object {
-	var obj *Object
+	return a.SearchVisibleSymbol(name)
+}
 
+// SearchVisibleSymbol searches for a symbol from the current block outwards,
+// returning its nearest definition
+func (a *Analyser) SearchVisibleSymbol(name int) *Object {
 	for i := a.level; i >= 0; i-- {
-		obj = a.symbolTable[i]
-
-		for obj != nil {
+		for obj := a.blocks()[i]; obj != nil; obj = obj.Next {
 			if obj.Name == name {
 				return obj
 			}
+		}
+	}
 
-			obj = obj.Next
+	return nil
+}
+
+// RequireSymbol searches for a symbol from the current block outwards,
+// failing with ErrUndefined if no visible block defines it
+func (a *Analyser) RequireSymbol(name int) (*Object, error) {
+	obj := a.SearchVisibleSymbol(name)
+	if obj == nil {
+		return nil, ErrUndefined
+	}
+	return obj, nil
+}
+
+// Shadows returns true if defining name in the current block would shadow
+// a symbol of an enclosing block
+func (a *Analyser) Shadows(name int) bool {
+	for i := a.level - 1; i >= 0; i-- {
+		for obj := a.blocks()[i]; obj != nil; obj = obj.Next {
+			if obj.Name == name {
+				return true
+			}
 		}
+	}
+
+	return false
+}
 
+// SizeOf returns the size of a type. Scalars take a single slot, the
+// universal and void types and anything that isn't a type taking none, as do
+// arrays and structs left unfinished.
+func (a *Analyser) SizeOf(obj *Object) int {
+	if obj == nil {
+		return 0
 	}
 
-	return obj
+	switch obj.Kind {
+	case KindScalarType:
+		if obj.Name == NameUniversal || obj.Name == NameVoid {
+			return 0
+		}
+		return 1
+	case KindAliasType:
+		base, err := resolveAlias(obj)
+		if err != nil {
+			return 0
+		}
+		return a.SizeOf(base)
+	case KindPointerType:
+		return PointerSize
+	case KindEnumType:
+		return 1
+	case KindArrayType:
+		arr, ok := obj.T.(Array)
+		if !ok {
+			return 0
+		}
+		return arr.NumElements * a.SizeOf(arr.ElemType)
+	case KindStructType:
+		s, ok := obj.T.(Struct)
+		if !ok {
+			return 0
+		}
+
+		size := 0
+		for f := s.Fields; f != nil; f = f.Next {
+			field, ok := f.T.(Field)
+			if !ok {
+				return 0
+			}
+			size += a.SizeOf(field.PType)
+		}
+		return size
+	}
+
+	return 0
+}
+
+// resolveAlias follows a chain of aliases, returning the first type that
+// isn't an alias, or ErrAliasCycle if the chain loops
+func resolveAlias(obj *Object) (*Object, error) {
+	seen := map[*Object]bool{}
+	for obj.Kind == KindAliasType {
+		if seen[obj] {
+			return nil, ErrAliasCycle
+		}
+		seen[obj] = true
+		obj = obj.T.(Alias).BaseType
+	}
+	return obj, nil
+}
+
+// FinalizeAlias sets the size of an alias to the size of the type it
+// refers to, following chains of aliases
+func (a *Analyser) FinalizeAlias(obj *Object) error {
+	base, err := resolveAlias(obj)
+	if err != nil {
+		return err
+	}
+
+	alias := obj.T.(Alias)
+	alias.Size = a.SizeOf(base)
+	obj.T = alias
+	return nil
+}
+
+// ElementType returns the element type of an array, following aliases, or
+// false if obj isn't an array. Each dimension of a multi-dimensional array
+// is peeled by a call.
+func (a *Analyser) ElementType(obj *Object) (*Object, bool) {
+	obj, err := resolveAlias(obj)
+	if err != nil || obj.Kind != KindArrayType {
+		return nil, false
+	}
+	return obj.T.(Array).ElemType, true
+}
+
+// ResolveFieldPath resolves a path of field names against root, a struct
+// type or a symbol of one, returning the type of the last field. Aliases are
+// followed along the way.
+func (a *Analyser) ResolveFieldPath(root *Object, path []int) (*Object, error) {
+	t := root
+	switch v := root.T.(type) {
+	case Var:
+		t = v.PType
+	case Param:
+		t = v.PType
+	case Field:
+		t = v.PType
+	}
+
+	for _, name := range path {
+		var err error
+		t, err = resolveAlias(t)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", name, err)
+		}
+
+		if t.Kind != KindStructType {
+			return nil, fmt.Errorf("field %d: %v is not a struct", name, t.Kind)
+		}
+
+		field := t.T.(Struct).Field(name)
+		if field == nil {
+			return nil, fmt.Errorf("field %d not found", name)
+		}
+		t = field.T.(Field).PType
+	}
+
+	return t, nil
 }
 
-// CheckTypes returns true if objects are of same type
+// ValidateStruct checks that no two fields of a struct share a name
+func (a *Analyser) ValidateStruct(s *Object) error {
+	if s.Kind != KindStructType {
+		return fmt.Errorf("%v is not a struct", s.Kind)
+	}
+
+	seen := map[int]bool{}
+	for f := s.T.(Struct).Fields; f != nil; f = f.Next {
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate field %d", f.Name)
+		}
+		seen[f.Name] = true
+	}
+
+	return nil
+}
+
+// FieldOffset returns the offset of a struct's field given its name, or false
+// if s isn't a struct or has no such field. Fields are packed, with no
+// padding between them, and laid out in the order of the Fields list, as the
+// semantic analyser indexes them.
+func (a *Analyser) FieldOffset(s *Object, field int) (int, bool) {
+	if s.Kind != KindStructType {
+		return 0, false
+	}
+
+	st, ok := s.T.(Struct)
+	if !ok {
+		return 0, false
+	}
+
+	offset := 0
+	for f := st.Fields; f != nil; f = f.Next {
+		if f.Name == field {
+			return offset, true
+		}
+
+		t, ok := f.T.(Field)
+		if !ok {
+			return 0, false
+		}
+		offset += a.SizeOf(t.PType)
+	}
+
+	return 0, false
+}
+
+// CheckTypes returns true if objects are of same type. Structs are of the
+// same type when they have the same fields, with the same names and types,
+// in the same order. Functions are of the same type when they take the same
+// number of parameters, of the same types, and return the same type.
 func (a *Analyser) CheckTypes(p1, p2 *Object) bool {
-	if p1 == p2 {
+	return a.CompareTypes(p1, p2) == nil
+}
+
+// coercions are the implicit conversions allowed between scalar types,
+// from one name to another
+var coercions = map[[2]int]bool{
+	{NameChar, NameInt}:      true,
+	{NameChar, NameUnsigned}: true,
+}
+
+// Coercible returns true if a value of type from can be used where one of
+// type to is expected. On top of types being the same, as CheckTypes
+// defines it, a char can be widened to an int or an unsigned. Ints and
+// unsigneds don't convert implicitly, as either can hold values the other
+// can't.
+func (a *Analyser) Coercible(from, to *Object) bool {
+	if a.CheckTypes(from, to) {
 		return true
+	}
+
+	from, err := resolveAlias(from)
+	if err != nil {
+		return false
+	}
+	to, err = resolveAlias(to)
+	if err != nil {
+		return false
+	}
+
+	return from.Kind == KindScalarType && to.Kind == KindScalarType &&
+		coercions[[2]int{from.Name, to.Name}]
+}
+
+// scalarNames are the names of the scalar types used in error messages
+var scalarNames = map[int]string{
+	NameInt:       "int",
+	NameChar:      "char",
+	NameBool:      "bool",
+	NameString:    "string",
+	NameUniversal: "universal",
+	NameVoid:      "void",
+	NameUnsigned:  "unsigned",
+}
+
+// CompareTypes returns nil if objects are of same type, as CheckTypes
+// defines it, or an error describing why they differ otherwise
+func (a *Analyser) CompareTypes(p1, p2 *Object) error {
+	return a.compareTypes(p1, p2, map[typePair]bool{})
+}
+
+// typePair is a pair of struct types being compared
+type typePair struct {
+	p1, p2 *Object
+}
+
+// compareTypes compares objects as CompareTypes does. Pairs of structs
+// already being compared are assumed to be of the same type, so structs
+// referring to themselves through their fields don't recurse forever.
+func (a *Analyser) compareTypes(p1, p2 *Object, visited map[typePair]bool) error {
+	if p1 == p2 {
+		return nil
+	} else if isVoid(p1) != isVoid(p2) {
+		// void equals only void, the universal type included
+		return fmt.Errorf("scalar %s vs %s", p1.displayName(), p2.displayName())
 	} else if p1 == PUniversalObj || p2 == PUniversalObj {
-		return true
+		return nil
 	} else if p1.Kind == KindUniversal || p2.Kind == KindUniversal {
-		return true
-	} else if p1.Kind == KindAliasType && p2.Kind != KindAliasType {
-		alias := p1.T.(Alias)
-		return a.CheckTypes(alias.BaseType, p2)
-	} else if p1.Kind != KindAliasType && p2.Kind == KindAliasType {
-		alias := p2.T.(Alias)
-		return a.CheckTypes(p1, alias.BaseType)
-	} else if p1.Kind == p1.Kind {
-		if p1.Kind == KindAliasType {
-			a1 := p1.T.(Alias)
-			a2 := p2.T.(Alias)
-			return a.CheckTypes(a1.BaseType, a2.BaseType)
+		return nil
+	} else if p1.Kind == KindAliasType || p2.Kind == KindAliasType {
+		base1, err := resolveAlias(p1)
+		if err != nil {
+			return err
+		}
+		base2, err := resolveAlias(p2)
+		if err != nil {
+			return err
+		}
+		return a.compareTypes(base1, base2, visited)
+	} else if p1.Kind != p2.Kind {
+		return fmt.Errorf("kind mismatch: %v vs %v", p1.Kind, p2.Kind)
+	} else {
+		if p1.Kind == KindScalarType {
+			if p1.Name != p2.Name {
+				return fmt.Errorf("scalar %s vs %s", scalarNames[p1.Name], scalarNames[p2.Name])
+			}
+			return nil
 		} else if p1.Kind == KindArrayType {
 			a1 := p1.T.(Array)
 			a2 := p2.T.(Array)
-			if a1.NumElements == a2.NumElements {
-				return a.CheckTypes(a1.ElemType, a2.ElemType)
+			if a1.NumElements != a2.NumElements {
+				return fmt.Errorf("array length mismatch: %d vs %d", a1.NumElements, a2.NumElements)
+			}
+			if err := a.compareTypes(a1.ElemType, a2.ElemType, visited); err != nil {
+				return fmt.Errorf("array element: %w", err)
 			}
+			return nil
 		} else if p1.Kind == KindStructType {
+			pair := typePair{p1, p2}
+			if visited[pair] {
+				return nil
+			}
+			visited[pair] = true
+
 			s1 := p1.T.(Struct)
 			s2 := p2.T.(Struct)
 
 			f1 := s1.Fields
 			f2 := s2.Fields
-			if f1 != nil && f2 != nil {
-				// TODO
+			for f1 != nil && f2 != nil {
+				if f1.Name != f2.Name {
+					return fmt.Errorf("struct field name mismatch: %d vs %d", f1.Name, f2.Name)
+				}
+
+				if err := a.compareTypes(f1.T.(Field).PType, f2.T.(Field).PType, visited); err != nil {
+					return fmt.Errorf("struct field %d: %w", f1.Name, err)
+				}
+
+				f1 = f1.Next
+				f2 = f2.Next
+			}
+
+			if f1 != nil || f2 != nil {
+				return fmt.Errorf("struct field count mismatch: %d vs %d", count(s1.Fields), count(s2.Fields))
+			}
+			return nil
+		} else if p1.Kind == KindPointerType {
+			if err := a.compareTypes(p1.T.(Pointer).Base, p2.T.(Pointer).Base, visited); err != nil {
+				return fmt.Errorf("pointer base: %w", err)
+			}
+			return nil
+		} else if p1.Kind == KindEnumType {
+			return errors.New("distinct enum types")
+		} else if p1.Kind == KindFunction {
+			fn1 := p1.T.(Function)
+			fn2 := p2.T.(Function)
+
+			pr1 := fn1.PParams
+			pr2 := fn2.PParams
+			for i := 0; pr1 != nil && pr2 != nil; i++ {
+				if err := a.compareTypes(pr1.T.(Param).PType, pr2.T.(Param).PType, visited); err != nil {
+					return fmt.Errorf("function parameter %d: %w", i, err)
+				}
+
+				pr1 = pr1.Next
+				pr2 = pr2.Next
+			}
+
+			if pr1 != nil || pr2 != nil {
+				return fmt.Errorf("function arity mismatch: %d vs %d", count(fn1.PParams), count(fn2.PParams))
 			}
+
+			if fn1.PRetType == nil || fn2.PRetType == nil {
+				if fn1.PRetType != fn2.PRetType {
+					return errors.New("function return type mismatch")
+				}
+				return nil
+			}
+			if err := a.compareTypes(fn1.PRetType, fn2.PRetType, visited); err != nil {
+				return fmt.Errorf("function return type: %w", err)
+			}
+			return nil
 		}
 	}
 
-	return false
+	return errors.New("incompatible types")
+}
+
+// isVoid returns true if obj is the void type, or an alias of it
+func isVoid(obj *Object) bool {
+	base, err := resolveAlias(obj)
+	return err == nil && base.Kind == KindScalarType && base.Name == NameVoid
+}
+
+// count returns the length of an object list
+func count(list *Object) int {
+	n := 0
+	for ; list != nil; list = list.Next {
+		n++
+	}
+	return n
+}
+
+// displayName returns the object's name, scalar types being named after
+// their type
+func (o *Object) displayName() string {
+	if name, ok := scalarNames[o.Name]; ok && o.Kind == KindScalarType {
+		return name
+	}
+	return fmt.Sprint(o.Name)
 }
 
+// String returns the object's name and kind, along with a summary of its
+// type for composite types
 func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("Kind: %v Name: %v Type: ", o.Kind, o.Name))
-	switch o.T.(type) {
+	sb.WriteString(fmt.Sprintf("Object{name:%s kind:%v", o.displayName(), o.Kind))
+
+	switch t := o.T.(type) {
 	case Alias:
-		sb.WriteString("Alias")
-	case Type:
-		sb.WriteString("Type")
+		if _, err := resolveAlias(o); err != nil {
+			sb.WriteString(" base:<cycle>")
+		} else {
+			sb.WriteString(fmt.Sprintf(" base:%v", t.BaseType))
+		}
 	case Array:
-		sb.WriteString("Array")
+		sb.WriteString(fmt.Sprintf(" elem:%v len:%d", t.ElemType, t.NumElements))
+	case Pointer:
+		sb.WriteString(fmt.Sprintf(" base:%v", t.Base))
 	case Struct:
-		sb.WriteString("Struct")
+		sb.WriteString(" fields:[")
+		for f := t.Fields; f != nil; f = f.Next {
+			sb.WriteString(fmt.Sprint(f.Name))
+			if f.Next != nil {
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("]")
 	case Function:
-		sb.WriteString("Function")
-	case Var:
-		sb.WriteString("Var")
-	case Param:
-		sb.WriteString("Param")
-	case Field:
-		sb.WriteString("Field")
-	default:
-		sb.WriteString("INVALID")
+		sb.WriteString(fmt.Sprintf(" params:%d", count(t.PParams)))
+	}
+	sb.WriteString("}")
+
+	return sb.String()
+}
+
+// Dump returns every block of the symbol table, from the outermost one in,
+// with their symbols, indented by level
+func (a *Analyser) Dump() string {
+	var sb strings.Builder
+
+	for i, block := range a.blocks() {
+		indent := strings.Repeat("  ", i)
+		sb.WriteString(fmt.Sprintf("%slevel %d:\n", indent, i))
+		for obj := block; obj != nil; obj = obj.Next {
+			sb.WriteString(fmt.Sprintf("%s  %v\n", indent, obj))
+		}
+	}
+
+	return sb.String()
+}
+
+// ToDOT returns the symbol table as a Graphviz graph, with an edge from each
+// block to its symbols and from each alias, pointer, array and struct to its
+// base, element and field types
+func (a *Analyser) ToDOT() string {
+	var sb strings.Builder
+	ids := map[*Object]string{}
+
+	var node func(o *Object) string
+	node = func(o *Object) string {
+		if id, ok := ids[o]; ok {
+			return id
+		}
+
+		id := fmt.Sprintf("obj%d", len(ids))
+		ids[o] = id
+		sb.WriteString(fmt.Sprintf("  %s [label=%q];\n", id, fmt.Sprintf("%s %v", o.displayName(), o.Kind)))
+
+		switch t := o.T.(type) {
+		case Alias:
+			if t.BaseType != nil {
+				sb.WriteString(fmt.Sprintf("  %s -> %s [label=\"base\"];\n", id, node(t.BaseType)))
+			}
+		case Array:
+			if t.ElemType != nil {
+				sb.WriteString(fmt.Sprintf("  %s -> %s [label=\"elem\"];\n", id, node(t.ElemType)))
+			}
+		case Pointer:
+			if t.Base != nil {
+				sb.WriteString(fmt.Sprintf("  %s -> %s [label=\"base\"];\n", id, node(t.Base)))
+			}
+		case Struct:
+			for f := t.Fields; f != nil; f = f.Next {
+				field, ok := f.T.(Field)
+				if !ok || field.PType == nil {
+					continue
+				}
+				sb.WriteString(fmt.Sprintf("  %s -> %s [label=\"field %d\"];\n", id, node(field.PType), f.Name))
+			}
+		}
+
+		return id
+	}
+
+	sb.WriteString("digraph symbols {\n")
+	for i, block := range a.blocks() {
+		sb.WriteString(fmt.Sprintf("  level%d [shape=box label=\"level %d\"];\n", i, i))
+		if i > 0 {
+			sb.WriteString(fmt.Sprintf("  level%d -> level%d;\n", i-1, i))
+		}
+		for obj := block; obj != nil; obj = obj.Next {
+			sb.WriteString(fmt.Sprintf("  level%d -> %s;\n", i, node(obj)))
+		}
 	}
+	sb.WriteString("}\n")
 
 	return sb.String()
 }
@@ -0,0 +1,1301 @@
+package scope
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lucbarr/sslang/lexical"
+	"github.com/stretchr/testify/assert"
+)
+
+type field struct {
+	name int
+	t    *Object
+}
+
+// newStruct builds a struct type object out of its fields, defining them in
+// a block of their own like the semantical analyser does
+func newStruct(a *Analyser, fields ...field) *Object {
+	a.NewBlock()
+	for i, f := range fields {
+		obj := a.DefineSymbol(f.name)
+		obj.Kind = KindField
+		obj.T = Field{PType: f.t, Index: i, Size: 1}
+	}
+	list := a.blocks()[a.level]
+	a.EndBlock()
+
+	return &Object{Kind: KindStructType, T: Struct{Fields: list, Size: len(fields)}}
+}
+
+func TestCheckTypesStructs(t *testing.T) {
+	a := &Analyser{}
+
+	tt := map[string]struct {
+		p1 *Object
+		p2 *Object
+
+		equal bool
+	}{
+		"test identical structs": {
+			p1:    newStruct(a, field{0, PIntObj}, field{1, PStringObj}),
+			p2:    newStruct(a, field{0, PIntObj}, field{1, PStringObj}),
+			equal: true,
+		},
+		"test reordered fields": {
+			p1:    newStruct(a, field{0, PIntObj}, field{1, PStringObj}),
+			p2:    newStruct(a, field{1, PStringObj}, field{0, PIntObj}),
+			equal: false,
+		},
+		"test different field names": {
+			p1:    newStruct(a, field{0, PIntObj}, field{1, PStringObj}),
+			p2:    newStruct(a, field{0, PIntObj}, field{2, PStringObj}),
+			equal: false,
+		},
+		"test different field types": {
+			p1:    newStruct(a, field{0, PIntObj}, field{1, PStringObj}),
+			p2:    newStruct(a, field{0, PIntObj}, field{1, PCharObj}),
+			equal: false,
+		},
+		"test different arity": {
+			p1:    newStruct(a, field{0, PIntObj}, field{1, PStringObj}),
+			p2:    newStruct(a, field{0, PIntObj}),
+			equal: false,
+		},
+		"test nested structs": {
+			p1:    newStruct(a, field{0, newStruct(a, field{1, PBoolObj})}),
+			p2:    newStruct(a, field{0, newStruct(a, field{1, PBoolObj})}),
+			equal: true,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, table.equal, a.CheckTypes(table.p1, table.p2))
+			assert.Equal(t, table.equal, a.CheckTypes(table.p2, table.p1))
+		})
+	}
+}
+
+func TestStructField(t *testing.T) {
+	a := &Analyser{}
+	st := newStruct(a, field{3, PIntObj}, field{7, PStringObj}).T.(Struct)
+
+	tt := map[string]struct {
+		name int
+
+		t     *Object
+		found bool
+	}{
+		"test first field": {
+			name:  3,
+			t:     PIntObj,
+			found: true,
+		},
+		"test second field": {
+			name:  7,
+			t:     PStringObj,
+			found: true,
+		},
+		"test missing field": {
+			name:  5,
+			found: false,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			f := st.Field(table.name)
+			if !table.found {
+				assert.Nil(t, f)
+				return
+			}
+
+			assert.Equal(t, table.name, f.Name)
+			assert.Equal(t, table.t, f.T.(Field).PType)
+		})
+	}
+}
+
+func TestDeepNesting(t *testing.T) {
+	a := &Analyser{}
+	global := a.DefineSymbol(0)
+
+	for i := 1; i <= 1000; i++ {
+		assert.Equal(t, i, a.NewBlock())
+		a.DefineSymbol(i)
+	}
+
+	assert.Equal(t, global, a.SearchGlobalSymbol(0))
+	assert.NotNil(t, a.SearchLocalSymbol(1000))
+	assert.Nil(t, a.SearchLocalSymbol(999))
+
+	for i := 999; i >= 0; i-- {
+		assert.Equal(t, i, a.EndBlock())
+	}
+
+	assert.Nil(t, a.SearchGlobalSymbol(1))
+	assert.Equal(t, 1, a.NewBlock())
+	assert.Nil(t, a.SearchLocalSymbol(1))
+}
+
+func TestEndBlockUnderflow(t *testing.T) {
+	a := &Analyser{}
+	global := a.DefineSymbol(1)
+
+	assert.Equal(t, -1, a.EndBlock())
+	assert.Equal(t, -1, a.EndBlock())
+	assert.Equal(t, global, a.SearchGlobalSymbol(1))
+
+	assert.Equal(t, 1, a.NewBlock())
+	local := a.DefineSymbol(2)
+	assert.Equal(t, local, a.SearchGlobalSymbol(2))
+	assert.Equal(t, global, a.SearchGlobalSymbol(1))
+	assert.Equal(t, 0, a.EndBlock())
+}
+
+func TestCheckTypesScalars(t *testing.T) {
+	a := &Analyser{}
+	intCopy := IntObj
+
+	tt := map[string]struct {
+		p1 *Object
+		p2 *Object
+
+		equal bool
+	}{
+		"test int and int": {
+			p1:    PIntObj,
+			p2:    PIntObj,
+			equal: true,
+		},
+		"test int and a copy of int": {
+			p1:    PIntObj,
+			p2:    &intCopy,
+			equal: true,
+		},
+		"test int and string": {
+			p1:    PIntObj,
+			p2:    PStringObj,
+			equal: false,
+		},
+		"test char and bool": {
+			p1:    PCharObj,
+			p2:    PBoolObj,
+			equal: false,
+		},
+		"test int and universal": {
+			p1:    PIntObj,
+			p2:    PUniversalObj,
+			equal: true,
+		},
+		"test alias of int and int": {
+			p1:    &Object{Kind: KindAliasType, T: Alias{BaseType: PIntObj, Size: 1}},
+			p2:    PIntObj,
+			equal: true,
+		},
+		"test alias of int and string": {
+			p1:    &Object{Kind: KindAliasType, T: Alias{BaseType: PIntObj, Size: 1}},
+			p2:    PStringObj,
+			equal: false,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, table.equal, a.CheckTypes(table.p1, table.p2))
+			assert.Equal(t, table.equal, a.CheckTypes(table.p2, table.p1))
+		})
+	}
+}
+
+func TestInitBuiltins(t *testing.T) {
+	lexer := lexical.NewLexer([]byte("var x: integer; var y: unsigned; var s: string;"))
+	_, err := lexer.Run()
+	assert.Nil(t, err)
+
+	names := lexer.Identifiers()
+	assert.NotContains(t, names, "integer")
+	assert.NotContains(t, names, "string")
+
+	a := &Analyser{}
+	a.NewBlock()
+	a.InitBuiltins(names)
+
+	tt := map[string]struct {
+		name int
+
+		t *Object
+	}{
+		"test unsigned": {
+			name: names["unsigned"],
+			t:    PUnsignedObj,
+		},
+		"test not a builtin": {
+			name: names["x"],
+			t:    nil,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			obj := a.SearchGlobalSymbol(table.name)
+			if table.t == nil {
+				assert.Nil(t, obj)
+				return
+			}
+
+			assert.Equal(t, KindAliasType, obj.Kind)
+			assert.True(t, a.CheckTypes(obj, table.t))
+			assert.Equal(t, a.SizeOf(table.t), obj.T.(Alias).Size)
+		})
+	}
+
+	assert.Nil(t, a.SearchLocalSymbol(names["unsigned"]))
+	a.EndBlock()
+	assert.NotNil(t, a.SearchLocalSymbol(names["unsigned"]))
+}
+
+func TestDefineSymbolChecked(t *testing.T) {
+	a := &Analyser{}
+
+	x, err := a.DefineSymbolChecked(1)
+	assert.Nil(t, err)
+	assert.NotNil(t, x)
+
+	_, err = a.DefineSymbolChecked(2)
+	assert.Nil(t, err)
+
+	dup, err := a.DefineSymbolChecked(1)
+	assert.Equal(t, ErrRedefinition, err)
+	assert.Nil(t, dup)
+	assert.Same(t, x, a.SearchLocalSymbol(1))
+
+	a.NewBlock()
+	inner, err := a.DefineSymbolChecked(1)
+	assert.Nil(t, err)
+	assert.True(t, x != inner)
+}
+
+func TestShadows(t *testing.T) {
+	a := &Analyser{}
+	a.DefineSymbol(1)
+	assert.False(t, a.Shadows(1))
+
+	a.NewBlock()
+	assert.True(t, a.Shadows(1))
+	assert.False(t, a.Shadows(2))
+	a.DefineSymbol(2)
+
+	a.NewBlock()
+	assert.True(t, a.Shadows(1))
+	assert.True(t, a.Shadows(2))
+
+	a.EndBlock()
+	assert.False(t, a.Shadows(2))
+}
+
+func TestSizeOf(t *testing.T) {
+	a := &Analyser{}
+	intArray := &Object{Kind: KindArrayType, T: Array{ElemType: PIntObj, NumElements: 10}}
+
+	tt := map[string]struct {
+		obj *Object
+
+		size int
+	}{
+		"test scalar": {
+			obj:  PIntObj,
+			size: 1,
+		},
+		"test universal": {
+			obj:  PUniversalObj,
+			size: 0,
+		},
+		"test array of scalars": {
+			obj:  intArray,
+			size: 10,
+		},
+		"test struct of two scalars": {
+			obj:  newStruct(a, field{0, PIntObj}, field{1, PCharObj}),
+			size: 2,
+		},
+		"test struct of arrays": {
+			obj:  newStruct(a, field{0, intArray}, field{1, PCharObj}),
+			size: 11,
+		},
+		"test array of structs": {
+			obj: &Object{Kind: KindArrayType, T: Array{
+				ElemType:    newStruct(a, field{0, PIntObj}, field{1, PCharObj}),
+				NumElements: 3,
+			}},
+			size: 6,
+		},
+		"test alias": {
+			obj:  &Object{Kind: KindAliasType, T: Alias{BaseType: intArray}},
+			size: 10,
+		},
+		"test not a type": {
+			obj:  &Object{Kind: KindVar, T: Var{PType: PIntObj}},
+			size: 0,
+		},
+		"test struct with an unfinished field": {
+			obj:  &Object{Kind: KindStructType, T: Struct{Fields: &Object{Next: &Object{Kind: KindField, T: Field{PType: PIntObj}}}}},
+			size: 0,
+		},
+		"test array of no element type": {
+			obj:  &Object{Kind: KindArrayType, T: Array{NumElements: 4}},
+			size: 0,
+		},
+		"test unfinished array": {
+			obj:  &Object{Kind: KindArrayType},
+			size: 0,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, table.size, a.SizeOf(table.obj))
+		})
+	}
+}
+
+func newFunction(ret *Object, params ...*Object) *Object {
+	var list *Object
+	for i := len(params) - 1; i >= 0; i-- {
+		list = &Object{Kind: KindParam, Next: list, T: Param{PType: params[i], Index: i, Size: 1}}
+	}
+
+	return &Object{Kind: KindFunction, T: Function{PRetType: ret, PParams: list, Params: len(params)}}
+}
+
+func TestCheckTypesFunctions(t *testing.T) {
+	a := &Analyser{}
+
+	tt := map[string]struct {
+		p1 *Object
+		p2 *Object
+
+		equal bool
+	}{
+		"test matching signatures": {
+			p1:    newFunction(PIntObj, PIntObj, PStringObj),
+			p2:    newFunction(PIntObj, PIntObj, PStringObj),
+			equal: true,
+		},
+		"test no parameters": {
+			p1:    newFunction(PBoolObj),
+			p2:    newFunction(PBoolObj),
+			equal: true,
+		},
+		"test different arity": {
+			p1:    newFunction(PIntObj, PIntObj),
+			p2:    newFunction(PIntObj, PIntObj, PIntObj),
+			equal: false,
+		},
+		"test different parameter type": {
+			p1:    newFunction(PIntObj, PIntObj, PCharObj),
+			p2:    newFunction(PIntObj, PIntObj, PStringObj),
+			equal: false,
+		},
+		"test different return type": {
+			p1:    newFunction(PIntObj, PIntObj),
+			p2:    newFunction(PBoolObj, PIntObj),
+			equal: false,
+		},
+		"test aliased parameter": {
+			p1:    newFunction(PIntObj, &Object{Kind: KindAliasType, T: Alias{BaseType: PIntObj}}),
+			p2:    newFunction(PIntObj, PIntObj),
+			equal: true,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, table.equal, a.CheckTypes(table.p1, table.p2))
+		})
+	}
+}
+
+func TestLocalSymbols(t *testing.T) {
+	a := &Analyser{}
+	outer := a.DefineSymbol(0)
+
+	a.NewBlock()
+	assert.Empty(t, a.LocalSymbols())
+
+	x := a.DefineSymbol(1)
+	y := a.DefineSymbol(2)
+	z := a.DefineSymbol(3)
+
+	symbols := a.LocalSymbols()
+	assert.Len(t, symbols, 3)
+	assert.Same(t, z, symbols[0])
+	assert.Same(t, y, symbols[1])
+	assert.Same(t, x, symbols[2])
+	for _, symbol := range symbols {
+		assert.True(t, symbol != outer)
+	}
+
+	var names []int
+	a.ForEachLocal(func(obj *Object) {
+		names = append(names, obj.Name)
+	})
+	assert.Equal(t, []int{3, 2, 1}, names)
+}
+
+func TestCompareTypes(t *testing.T) {
+	a := &Analyser{}
+
+	tt := map[string]struct {
+		p1 *Object
+		p2 *Object
+
+		err string
+	}{
+		"test same type": {
+			p1: PIntObj,
+			p2: &Object{Kind: KindAliasType, T: Alias{BaseType: PIntObj}},
+		},
+		"test scalar mismatch": {
+			p1:  PIntObj,
+			p2:  PStringObj,
+			err: "scalar int vs string",
+		},
+		"test array length mismatch": {
+			p1:  &Object{Kind: KindArrayType, T: Array{ElemType: PIntObj, NumElements: 3}},
+			p2:  &Object{Kind: KindArrayType, T: Array{ElemType: PIntObj, NumElements: 4}},
+			err: "array length mismatch: 3 vs 4",
+		},
+		"test array element mismatch": {
+			p1:  &Object{Kind: KindArrayType, T: Array{ElemType: PIntObj, NumElements: 3}},
+			p2:  &Object{Kind: KindArrayType, T: Array{ElemType: PCharObj, NumElements: 3}},
+			err: "array element: scalar int vs char",
+		},
+		"test struct field mismatch": {
+			p1:  newStruct(a, field{0, PIntObj}, field{1, PBoolObj}),
+			p2:  newStruct(a, field{0, PIntObj}, field{1, PStringObj}),
+			err: "struct field 1: scalar bool vs string",
+		},
+		"test struct field count mismatch": {
+			p1:  newStruct(a, field{0, PIntObj}),
+			p2:  newStruct(a, field{1, PIntObj}, field{0, PIntObj}),
+			err: "struct field count mismatch: 1 vs 2",
+		},
+		"test function arity mismatch": {
+			p1:  newFunction(PIntObj, PIntObj),
+			p2:  newFunction(PIntObj),
+			err: "function arity mismatch: 1 vs 0",
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			err := a.CompareTypes(table.p1, table.p2)
+			if table.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, table.err)
+			}
+		})
+	}
+}
+
+func TestKindString(t *testing.T) {
+	assert.Equal(t, "ArrayType", KindArrayType.String())
+	assert.Equal(t, "Undefined", Kind(KindUndefined).String())
+	assert.Equal(t, "Kind(42)", Kind(42).String())
+}
+
+func TestObjectString(t *testing.T) {
+	a := &Analyser{}
+
+	tt := map[string]struct {
+		obj *Object
+
+		str string
+	}{
+		"test scalar": {
+			obj: PIntObj,
+			str: "Object{name:int kind:ScalarType}",
+		},
+		"test array": {
+			obj: &Object{Name: 3, Kind: KindArrayType, T: Array{ElemType: PCharObj, NumElements: 10}},
+			str: "Object{name:3 kind:ArrayType elem:Object{name:char kind:ScalarType} len:10}",
+		},
+		"test struct": {
+			obj: newStruct(a, field{1, PIntObj}, field{2, PIntObj}),
+			str: "Object{name:0 kind:StructType fields:[2 1]}",
+		},
+		"test var": {
+			obj: &Object{Name: 5, Kind: KindVar, T: Var{PType: PIntObj}},
+			str: "Object{name:5 kind:Var}",
+		},
+		"test nil": {
+			obj: nil,
+			str: "<nil>",
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, table.str, table.obj.String())
+		})
+	}
+}
+
+func TestLevel(t *testing.T) {
+	a := &Analyser{}
+	assert.Equal(t, 0, a.Level())
+
+	a.NewBlock()
+	a.NewBlock()
+	assert.Equal(t, 2, a.Level())
+
+	a.EndBlock()
+	assert.Equal(t, 1, a.Level())
+}
+
+func TestReset(t *testing.T) {
+	a := &Analyser{}
+	a.DefineSymbol(0)
+	a.NewBlock()
+	a.DefineSymbol(1)
+
+	a.Reset()
+	assert.Equal(t, 0, a.Level())
+	assert.Nil(t, a.SearchGlobalSymbol(0))
+	assert.Nil(t, a.SearchGlobalSymbol(1))
+
+	obj := a.DefineSymbol(2)
+	assert.Same(t, obj, a.SearchGlobalSymbol(2))
+}
+
+func TestCheckTypesRecursiveStructs(t *testing.T) {
+	a := &Analyser{}
+
+	// node1 and node2 each refer to the other, self to itself
+	node1 := newStruct(a, field{0, nil}, field{1, PIntObj})
+	node2 := newStruct(a, field{0, node1}, field{1, PIntObj})
+	node1.T.(Struct).Field(0).T = Field{PType: node2}
+	self := newStruct(a, field{0, nil}, field{1, PIntObj})
+	self.T.(Struct).Field(0).T = Field{PType: self}
+
+	other := newStruct(a, field{0, nil}, field{1, PCharObj})
+	other.T.(Struct).Field(0).T = Field{PType: other}
+
+	tt := map[string]struct {
+		p1 *Object
+		p2 *Object
+
+		equal bool
+	}{
+		"test mutually recursive structs": {
+			p1:    node1,
+			p2:    node2,
+			equal: true,
+		},
+		"test self recursive struct": {
+			p1:    node1,
+			p2:    self,
+			equal: true,
+		},
+		"test recursive structs with different fields": {
+			p1:    self,
+			p2:    other,
+			equal: false,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, table.equal, a.CheckTypes(table.p1, table.p2))
+		})
+	}
+}
+
+func TestCheckTypesKindMismatch(t *testing.T) {
+	a := &Analyser{}
+	array := &Object{Kind: KindArrayType, T: Array{ElemType: PIntObj, NumElements: 1}}
+
+	tt := map[string]struct {
+		p1 *Object
+		p2 *Object
+
+		err string
+	}{
+		"test array vs struct": {
+			p1:  array,
+			p2:  newStruct(a, field{0, PIntObj}),
+			err: "kind mismatch: ArrayType vs StructType",
+		},
+		"test array vs scalar": {
+			p1:  array,
+			p2:  PIntObj,
+			err: "kind mismatch: ArrayType vs ScalarType",
+		},
+		"test function vs array": {
+			p1:  newFunction(PIntObj),
+			p2:  array,
+			err: "kind mismatch: Function vs ArrayType",
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			assert.False(t, a.CheckTypes(table.p1, table.p2))
+			assert.EqualError(t, a.CompareTypes(table.p1, table.p2), table.err)
+		})
+	}
+}
+
+func TestSearchVisibleSymbol(t *testing.T) {
+	a := &Analyser{}
+	global := a.DefineSymbol(0)
+	other := a.DefineSymbol(1)
+
+	a.NewBlock()
+	a.NewBlock()
+	inner := a.DefineSymbol(0)
+
+	assert.Same(t, inner, a.SearchVisibleSymbol(0))
+	assert.Same(t, other, a.SearchVisibleSymbol(1))
+	assert.Nil(t, a.SearchVisibleSymbol(2))
+
+	a.EndBlock()
+	assert.Same(t, global, a.SearchVisibleSymbol(0))
+}
+
+func TestDefineSymbolAt(t *testing.T) {
+	a := &Analyser{}
+	obj := a.DefineSymbolAt(0, 4, 3)
+
+	found := a.SearchLocalSymbol(0)
+	assert.Same(t, obj, found)
+	assert.Equal(t, 4, found.DeclLine)
+	assert.Equal(t, 3, found.DeclColumn)
+	assert.Equal(t, KindUndefined, int(found.Kind))
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	a := &Analyser{}
+	a.DefineSymbol(0)
+
+	a.NewBlock()
+	x := a.DefineSymbol(1)
+	marker := a.Snapshot()
+
+	a.DefineSymbol(2)
+	a.DefineSymbol(3)
+	a.Restore(marker)
+
+	assert.Same(t, x, a.SearchLocalSymbol(1))
+	assert.Nil(t, a.SearchGlobalSymbol(2))
+	assert.Nil(t, a.SearchGlobalSymbol(3))
+	assert.NotNil(t, a.SearchGlobalSymbol(0))
+
+	a.Restore(a.Snapshot())
+	assert.Len(t, a.LocalSymbols(), 1)
+}
+
+func TestFieldOffset(t *testing.T) {
+	a := &Analyser{}
+	array := &Object{Kind: KindArrayType, T: Array{ElemType: PIntObj, NumElements: 4}}
+
+	// fields are listed most recently defined first
+	s := newStruct(a, field{2, PCharObj}, field{1, array}, field{0, PIntObj})
+
+	tt := map[string]struct {
+		s     *Object
+		field int
+
+		offset int
+		ok     bool
+	}{
+		"test first field": {
+			s:      s,
+			field:  0,
+			offset: 0,
+			ok:     true,
+		},
+		"test second field": {
+			s:      s,
+			field:  1,
+			offset: 1,
+			ok:     true,
+		},
+		"test field after array": {
+			s:      s,
+			field:  2,
+			offset: 5,
+			ok:     true,
+		},
+		"test missing field": {
+			s:     s,
+			field: 3,
+		},
+		"test not a struct": {
+			s:     array,
+			field: 0,
+		},
+		"test after an unfinished field": {
+			s:     &Object{Kind: KindStructType, T: Struct{Fields: &Object{Name: 0, Next: &Object{Name: 1, Kind: KindField, T: Field{PType: PIntObj}}}}},
+			field: 1,
+		},
+		"test unfinished struct": {
+			s:     &Object{Kind: KindStructType},
+			field: 0,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			offset, ok := a.FieldOffset(table.s, table.field)
+			assert.Equal(t, table.ok, ok)
+			assert.Equal(t, table.offset, offset)
+		})
+	}
+}
+
+func TestDefineConst(t *testing.T) {
+	a := &Analyser{}
+	c := a.DefineConst(0, PIntObj)
+	v := a.DefineSymbol(1)
+	v.Kind = KindVar
+	v.T = Var{PType: PIntObj}
+
+	assert.Same(t, c, a.SearchGlobalSymbol(0))
+	assert.Equal(t, KindConst, c.Kind)
+	assert.Equal(t, Const{PType: PIntObj}, c.T)
+	assert.False(t, c.IsMutable())
+	assert.True(t, v.IsMutable())
+}
+
+func TestForwardDeclarations(t *testing.T) {
+	a := &Analyser{}
+	f := a.DeclareForward(0)
+	g := a.DeclareForward(1)
+	a.DefineSymbol(2)
+
+	assert.Equal(t, KindUndefined, int(f.Kind))
+	assert.Equal(t, []*Object{g, f}, a.PendingForwards())
+
+	a.NewBlock()
+	found := a.SearchGlobalSymbol(0)
+	assert.Same(t, f, found)
+	assert.Empty(t, a.PendingForwards())
+	a.EndBlock()
+
+	fn := Function{PRetType: PIntObj}
+	assert.NoError(t, a.Complete(found, KindFunction, fn))
+	assert.Equal(t, KindFunction, f.Kind)
+	assert.Equal(t, fn, f.T)
+	assert.False(t, f.Forward)
+	assert.Equal(t, []*Object{g}, a.PendingForwards())
+
+	assert.Equal(t, ErrNotForward, a.Complete(f, KindFunction, fn))
+	assert.Equal(t, ErrNotForward, a.Complete(a.SearchGlobalSymbol(2), KindVar, Var{}))
+}
+
+func TestDump(t *testing.T) {
+	a := &Analyser{}
+	x := a.DefineSymbol(1)
+	x.Kind = KindVar
+	x.T = Var{PType: PIntObj}
+	a.NewBlock()
+	a.DefineSymbol(2)
+	arr := a.DefineSymbol(3)
+	arr.Kind = KindArrayType
+	arr.T = Array{ElemType: PCharObj, NumElements: 2}
+
+	expected := "level 0:\n" +
+		"  Object{name:1 kind:Var}\n" +
+		"  level 1:\n" +
+		"    Object{name:3 kind:ArrayType elem:Object{name:char kind:ScalarType} len:2}\n" +
+		"    Object{name:2 kind:Undefined}\n"
+	assert.Equal(t, expected, a.Dump())
+}
+
+func TestToDOT(t *testing.T) {
+	a := &Analyser{}
+	x := a.DefineSymbol(1)
+	x.Kind = KindVar
+	x.T = Var{PType: PIntObj}
+	a.NewBlock()
+	arr := a.DefineSymbol(3)
+	arr.Kind = KindArrayType
+	arr.T = Array{ElemType: PCharObj, NumElements: 2}
+
+	dot := a.ToDOT()
+	assert.Contains(t, dot, "digraph symbols {\n")
+	assert.Contains(t, dot, "  level0 -> level1;\n")
+	assert.Contains(t, dot, "  obj0 [label=\"1 Var\"];\n")
+	assert.Contains(t, dot, "  level0 -> obj0;\n")
+	assert.Contains(t, dot, "  obj1 [label=\"3 ArrayType\"];\n")
+	assert.Contains(t, dot, "  obj2 [label=\"char ScalarType\"];\n")
+	assert.Contains(t, dot, "  obj1 -> obj2 [label=\"elem\"];\n")
+	assert.Contains(t, dot, "  level1 -> obj1;\n")
+}
+
+func TestPointers(t *testing.T) {
+	a := &Analyser{}
+	intPointer := &Object{Kind: KindPointerType, T: Pointer{Base: PIntObj}}
+
+	tt := map[string]struct {
+		p1 *Object
+		p2 *Object
+
+		err string
+	}{
+		"test pointers to int": {
+			p1: intPointer,
+			p2: &Object{Kind: KindPointerType, T: Pointer{Base: PIntObj}},
+		},
+		"test aliased pointer base": {
+			p1: intPointer,
+			p2: &Object{Kind: KindPointerType, T: Pointer{Base: &Object{Kind: KindAliasType, T: Alias{BaseType: PIntObj}}}},
+		},
+		"test pointer vs universal": {
+			p1: intPointer,
+			p2: PUniversalObj,
+		},
+		"test pointers to different types": {
+			p1:  intPointer,
+			p2:  &Object{Kind: KindPointerType, T: Pointer{Base: PCharObj}},
+			err: "pointer base: scalar int vs char",
+		},
+		"test pointer vs int": {
+			p1:  intPointer,
+			p2:  PIntObj,
+			err: "kind mismatch: PointerType vs ScalarType",
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			err := a.CompareTypes(table.p1, table.p2)
+			if table.err == "" {
+				assert.NoError(t, err)
+				assert.True(t, a.CheckTypes(table.p1, table.p2))
+			} else {
+				assert.EqualError(t, err, table.err)
+				assert.False(t, a.CheckTypes(table.p1, table.p2))
+			}
+		})
+	}
+
+	array := &Object{Kind: KindArrayType, T: Array{ElemType: PIntObj, NumElements: 10}}
+	assert.Equal(t, PointerSize, a.SizeOf(&Object{Kind: KindPointerType, T: Pointer{Base: array}}))
+	assert.True(t, KindPointerType.IsType())
+	assert.Equal(t, "Object{name:0 kind:PointerType base:Object{name:int kind:ScalarType}}", intPointer.String())
+}
+
+func newArray(elem *Object, n int) *Object {
+	return &Object{Kind: KindArrayType, T: Array{ElemType: elem, NumElements: n}}
+}
+
+func TestMultiDimensionalArrays(t *testing.T) {
+	a := &Analyser{}
+	matrix := newArray(newArray(PIntObj, 3), 2)
+
+	row, ok := a.ElementType(matrix)
+	assert.True(t, ok)
+	assert.True(t, a.CheckTypes(newArray(PIntObj, 3), row))
+
+	elem, ok := a.ElementType(row)
+	assert.True(t, ok)
+	assert.Same(t, PIntObj, elem)
+
+	_, ok = a.ElementType(elem)
+	assert.False(t, ok)
+
+	aliased, ok := a.ElementType(&Object{Kind: KindAliasType, T: Alias{BaseType: matrix}})
+	assert.True(t, ok)
+	assert.Same(t, row, aliased)
+
+	assert.NoError(t, a.CompareTypes(matrix, newArray(newArray(PIntObj, 3), 2)))
+	assert.EqualError(t, a.CompareTypes(matrix, newArray(newArray(PIntObj, 4), 2)), "array element: array length mismatch: 3 vs 4")
+	assert.EqualError(t, a.CompareTypes(matrix, newArray(newArray(PCharObj, 3), 2)), "array element: array element: scalar int vs char")
+	assert.EqualError(t, a.CompareTypes(matrix, newArray(newArray(PIntObj, 3), 3)), "array length mismatch: 2 vs 3")
+	assert.EqualError(t, a.CompareTypes(matrix, newArray(PIntObj, 2)), "array element: kind mismatch: ArrayType vs ScalarType")
+}
+
+func TestResolveFieldPath(t *testing.T) {
+	a := &Analyser{}
+	inner := newStruct(a, field{10, PCharObj}, field{11, PIntObj})
+	aliased := &Object{Kind: KindAliasType, T: Alias{BaseType: inner}}
+	outer := newStruct(a, field{1, aliased}, field{2, PBoolObj})
+	variable := &Object{Name: 0, Kind: KindVar, T: Var{PType: outer}}
+
+	tt := map[string]struct {
+		root *Object
+		path []int
+
+		t   *Object
+		err string
+	}{
+		"test one level": {
+			root: outer,
+			path: []int{2},
+			t:    PBoolObj,
+		},
+		"test two levels through an alias": {
+			root: outer,
+			path: []int{1, 11},
+			t:    PIntObj,
+		},
+		"test from a variable": {
+			root: variable,
+			path: []int{1, 10},
+			t:    PCharObj,
+		},
+		"test empty path": {
+			root: outer,
+			path: []int{},
+			t:    outer,
+		},
+		"test unknown field": {
+			root: outer,
+			path: []int{1, 12},
+			err:  "field 12 not found",
+		},
+		"test field of a scalar": {
+			root: outer,
+			path: []int{2, 3},
+			err:  "field 3: ScalarType is not a struct",
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			obj, err := a.ResolveFieldPath(table.root, table.path)
+			if table.err != "" {
+				assert.EqualError(t, err, table.err)
+				assert.Nil(t, obj)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Same(t, table.t, obj)
+		})
+	}
+}
+
+func TestVoid(t *testing.T) {
+	a := &Analyser{}
+
+	assert.True(t, a.CheckTypes(PVoidObj, PVoidObj))
+	assert.True(t, a.CheckTypes(PVoidObj, &Object{Name: NameVoid, Kind: KindScalarType}))
+	assert.False(t, a.CheckTypes(PVoidObj, PIntObj))
+	assert.False(t, a.CheckTypes(PIntObj, PVoidObj))
+	assert.EqualError(t, a.CompareTypes(PVoidObj, PIntObj), "scalar void vs int")
+	assert.False(t, a.CheckTypes(PVoidObj, PUniversalObj))
+	assert.False(t, a.CheckTypes(PUniversalObj, PVoidObj))
+	assert.EqualError(t, a.CompareTypes(PVoidObj, PUniversalObj), "scalar void vs universal")
+	assert.True(t, a.CheckTypes(PVoidObj, &Object{Kind: KindAliasType, T: Alias{BaseType: PVoidObj}}))
+	assert.Equal(t, 0, a.SizeOf(PVoidObj))
+
+	assert.True(t, a.CheckTypes(newFunction(PVoidObj, PIntObj), newFunction(PVoidObj, PIntObj)))
+	assert.EqualError(t, a.CompareTypes(newFunction(PVoidObj, PIntObj), newFunction(PIntObj, PIntObj)),
+		"function return type: scalar void vs int")
+}
+
+func TestCoercible(t *testing.T) {
+	a := &Analyser{}
+
+	tt := map[string]struct {
+		from *Object
+		to   *Object
+
+		coercible bool
+	}{
+		"test same type": {
+			from:      PIntObj,
+			to:        PIntObj,
+			coercible: true,
+		},
+		"test char to int": {
+			from:      PCharObj,
+			to:        PIntObj,
+			coercible: true,
+		},
+		"test aliased char to int": {
+			from:      &Object{Kind: KindAliasType, T: Alias{BaseType: PCharObj}},
+			to:        &Object{Kind: KindAliasType, T: Alias{BaseType: PIntObj}},
+			coercible: true,
+		},
+		"test int to char": {
+			from:      PIntObj,
+			to:        PCharObj,
+			coercible: false,
+		},
+		"test int to string": {
+			from:      PIntObj,
+			to:        PStringObj,
+			coercible: false,
+		},
+		"test any to universal": {
+			from:      newArray(PIntObj, 2),
+			to:        PUniversalObj,
+			coercible: true,
+		},
+		"test char to unsigned": {
+			from:      PCharObj,
+			to:        PUnsignedObj,
+			coercible: true,
+		},
+		"test unsigned to int": {
+			from:      PUnsignedObj,
+			to:        PIntObj,
+			coercible: false,
+		},
+		"test int to unsigned": {
+			from:      PIntObj,
+			to:        PUnsignedObj,
+			coercible: false,
+		},
+		"test array of chars to array of ints": {
+			from:      newArray(PCharObj, 2),
+			to:        newArray(PIntObj, 2),
+			coercible: false,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, table.coercible, a.Coercible(table.from, table.to))
+		})
+	}
+}
+
+func TestSymbolCount(t *testing.T) {
+	a := &Analyser{}
+	assert.Equal(t, 0, a.SymbolCount(0))
+
+	a.DefineSymbol(0)
+	a.DefineSymbol(1)
+	a.DefineSymbol(2)
+	a.NewBlock()
+	a.DefineSymbol(3)
+	a.DefineSymbol(4)
+	a.NewBlock()
+
+	assert.Equal(t, 3, a.SymbolCount(0))
+	assert.Equal(t, 2, a.SymbolCount(1))
+	assert.Equal(t, 0, a.SymbolCount(2))
+	assert.Equal(t, 0, a.SymbolCount(3))
+	assert.Equal(t, 0, a.SymbolCount(-1))
+}
+
+func TestValidateStruct(t *testing.T) {
+	a := &Analyser{}
+
+	tt := map[string]struct {
+		s *Object
+
+		err string
+	}{
+		"test distinct fields": {
+			s: newStruct(a, field{0, PIntObj}, field{1, PIntObj}),
+		},
+		"test empty struct": {
+			s: newStruct(a),
+		},
+		"test duplicate field": {
+			s:   newStruct(a, field{0, PIntObj}, field{1, PIntObj}, field{0, PCharObj}),
+			err: "duplicate field 0",
+		},
+		"test not a struct": {
+			s:   newArray(PIntObj, 2),
+			err: "ArrayType is not a struct",
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			err := a.ValidateStruct(table.s)
+			if table.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, table.err)
+			}
+		})
+	}
+}
+
+func TestCompleteValidatesStructs(t *testing.T) {
+	a := &Analyser{}
+	obj := a.DeclareForward(0)
+
+	invalid := newStruct(a, field{1, PIntObj}, field{1, PCharObj}).T
+	assert.EqualError(t, a.Complete(obj, KindStructType, invalid), "duplicate field 1")
+	assert.True(t, obj.Forward)
+
+	valid := newStruct(a, field{1, PIntObj}, field{2, PCharObj}).T
+	assert.NoError(t, a.Complete(obj, KindStructType, valid))
+	assert.False(t, obj.Forward)
+	assert.Equal(t, valid, obj.T)
+}
+
+func TestFinalizeAlias(t *testing.T) {
+	a := &Analyser{}
+	array := newArray(PIntObj, 4)
+	alias := &Object{Kind: KindAliasType, T: Alias{BaseType: array}}
+	chained := &Object{Kind: KindAliasType, T: Alias{BaseType: alias}}
+
+	assert.NoError(t, a.FinalizeAlias(chained))
+	assert.Equal(t, 4*a.SizeOf(PIntObj), chained.T.(Alias).Size)
+	assert.Equal(t, 4*a.SizeOf(PIntObj), a.SizeOf(chained))
+
+	assert.NoError(t, a.FinalizeAlias(alias))
+	assert.Equal(t, 4, alias.T.(Alias).Size)
+
+	cycle1 := &Object{Kind: KindAliasType}
+	cycle2 := &Object{Kind: KindAliasType, T: Alias{BaseType: cycle1}}
+	cycle1.T = Alias{BaseType: cycle2}
+	assert.Equal(t, ErrAliasCycle, a.FinalizeAlias(cycle1))
+	assert.Equal(t, 0, cycle1.T.(Alias).Size)
+	assert.Equal(t, 0, a.SizeOf(cycle2))
+
+	_, ok := a.ElementType(cycle1)
+	assert.False(t, ok)
+	_, err := a.ResolveFieldPath(cycle1, []int{1})
+	assert.True(t, errors.Is(err, ErrAliasCycle))
+	assert.False(t, a.CheckTypes(cycle1, PIntObj))
+	assert.False(t, a.Coercible(PCharObj, cycle2))
+	assert.Equal(t, "Object{name:0 kind:AliasType base:<cycle>}", cycle1.String())
+}
+
+func TestRegisterBuiltinFunc(t *testing.T) {
+	a := &Analyser{}
+	a.NewBlock()
+
+	params := &Object{Kind: KindParam, T: Param{PType: PStringObj}}
+	length := a.RegisterBuiltinFunc(7, params, PIntObj)
+	assert.Nil(t, a.SearchLocalSymbol(7))
+
+	a.NewBlock()
+	found := a.SearchGlobalSymbol(7)
+	assert.Same(t, length, found)
+	assert.Equal(t, KindFunction, found.Kind)
+	assert.True(t, a.CheckTypes(newFunction(PIntObj, PStringObj), found))
+
+	fn := found.T.(Function)
+	assert.Equal(t, 1, fn.Params)
+	assert.Same(t, PIntObj, fn.PRetType)
+
+	a.EndBlock()
+	a.EndBlock()
+	assert.Same(t, length, a.SearchLocalSymbol(7))
+}
+
+func TestRequireSymbol(t *testing.T) {
+	a := &Analyser{}
+	global := a.DefineSymbol(0)
+	a.NewBlock()
+	local := a.DefineSymbol(1)
+
+	tt := map[string]struct {
+		name int
+
+		obj *Object
+		err error
+	}{
+		"test local symbol": {
+			name: 1,
+			obj:  local,
+		},
+		"test global symbol": {
+			name: 0,
+			obj:  global,
+		},
+		"test undefined symbol": {
+			name: 2,
+			err:  ErrUndefined,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			obj, err := a.RequireSymbol(table.name)
+			assert.Equal(t, table.err, err)
+			assert.Same(t, table.obj, obj)
+		})
+	}
+
+	a.EndBlock()
+	_, err := a.RequireSymbol(1)
+	assert.EqualError(t, err, "undefined symbol")
+}
+
+func TestUnused(t *testing.T) {
+	a := &Analyser{}
+	a.NewBlock()
+
+	used := a.DefineSymbol(0)
+	used.Kind = KindVar
+	unused := a.DefineSymbol(1)
+	unused.Kind = KindVar
+	param := a.DefineSymbol(2)
+	param.Kind = KindParam
+
+	a.MarkUsed(used)
+	assert.Empty(t, a.Unused())
+
+	a.EndBlock()
+	assert.Equal(t, []*Object{unused}, a.Unused())
+
+	a.Reset()
+	assert.Empty(t, a.Unused())
+}
+
+func TestUnsigned(t *testing.T) {
+	a := &Analyser{}
+
+	assert.True(t, a.CheckTypes(PUnsignedObj, PUnsignedObj))
+	assert.False(t, a.CheckTypes(PIntObj, PUnsignedObj))
+	assert.EqualError(t, a.CompareTypes(PUnsignedObj, PIntObj), "scalar unsigned vs int")
+	assert.Equal(t, 1, a.SizeOf(PUnsignedObj))
+}
+
+func TestEnums(t *testing.T) {
+	a := &Analyser{}
+	color := a.DefineEnum(0, []int{1, 2})
+	other := a.DefineEnum(3, []int{4})
+
+	tt := map[string]struct {
+		name int
+
+		value int
+	}{
+		"test first member": {
+			name:  1,
+			value: 0,
+		},
+		"test second member": {
+			name:  2,
+			value: 1,
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			member := color.T.(Enum).Member(table.name)
+			assert.Equal(t, KindConst, member.Kind)
+			assert.Equal(t, Const{PType: color, Value: table.value}, member.T)
+
+			assert.Same(t, member, a.SearchGlobalSymbol(table.name))
+			assert.False(t, member.IsMutable())
+		})
+	}
+
+	assert.Nil(t, color.T.(Enum).Member(4))
+	assert.Nil(t, color.T.(Enum).Member(0))
+	assert.Nil(t, other.T.(Enum).Member(1))
+	assert.Same(t, color, a.SearchGlobalSymbol(0))
+	assert.True(t, a.CheckTypes(color, color))
+	assert.True(t, a.CheckTypes(color, &Object{Kind: KindAliasType, T: Alias{BaseType: color}}))
+	assert.EqualError(t, a.CompareTypes(color, other), "distinct enum types")
+	assert.False(t, a.CheckTypes(color, PIntObj))
+	assert.Equal(t, 1, a.SizeOf(color))
+}
@@ -1014,14 +1014,7 @@ func (a *Analyser) Parse(r int) {
 			LV0Static.Attribute = lv0
 		} else {
 			st := t.T.(scope.Struct)
-			p = st.Fields
-
-			for p != nil {
-				if ids := IDStatic.Attribute.(ID); p.Name == ids.Name {
-					break
-				}
-				p = p.Next
-			}
+			p = st.Field(IDStatic.Attribute.(ID).Name)
 
 			if p == nil {
 				lv0 := LV0Static.Attribute.(LV)
@@ -2,6 +2,7 @@ package syntatical
 
 import (
 	"fmt"
+	"iter"
 	"strconv"
 
 	"github.com/lucbarr/sslang/lexical"
@@ -26,9 +27,18 @@ func NewParser() (*Parser, error) {
 
 // Run runs the lexical analysis
 func (p *Parser) Run(lexer *lexical.Lexer, out string) error {
+	next, stop := iter.Pull2(lexer.All())
+	defer stop()
+
 	state := 0
-	currentToken, _ := lexer.NextToken()
-	action := p.actionTable[state][currentToken]
+	currentToken, err, _ := next()
+	if err != nil {
+		return err
+	}
+	action, ok := p.action(state, currentToken.Type)
+	if !ok {
+		return syntaxError(currentToken)
+	}
 
 	sem := semantics.NewAnalyser(lexer, out)
 	defer sem.Close()
@@ -38,8 +48,14 @@ func (p *Parser) Run(lexer *lexical.Lexer, out string) error {
 		if ok {
 			p.stateStack = append(p.stateStack, state)
 
-			currentToken, _ = lexer.NextToken()
-			action = p.actionTable[state][currentToken]
+			currentToken, err, _ = next()
+			if err != nil {
+				return err
+			}
+			action, ok = p.action(state, currentToken.Type)
+			if !ok {
+				return syntaxError(currentToken)
+			}
 
 			continue
 		}
@@ -52,7 +68,10 @@ func (p *Parser) Run(lexer *lexical.Lexer, out string) error {
 			temporaryState := p.stateStack[len(p.stateStack)-1]
 
 			leftToken := nonterminals.RuleLeftTokens[rule-1]
-			stateString := p.actionTable[temporaryState][leftToken]
+			stateString, ok := p.action(temporaryState, leftToken)
+			if !ok {
+				return syntaxError(currentToken)
+			}
 
 			state, err := strconv.Atoi(stateString)
 			if err != nil {
@@ -61,7 +80,10 @@ func (p *Parser) Run(lexer *lexical.Lexer, out string) error {
 
 			p.stateStack = append(p.stateStack, state)
 
-			action = p.actionTable[state][currentToken]
+			action, ok = p.action(state, currentToken.Type)
+			if !ok {
+				return syntaxError(currentToken)
+			}
 
 			sem.Parse(rule)
 			continue
@@ -73,6 +95,21 @@ func (p *Parser) Run(lexer *lexical.Lexer, out string) error {
 	return nil
 }
 
+// action returns the entry of the action table for state and column, false
+// if the table has no such column, as for the tokens numbered past it
+func (p *Parser) action(state, column int) (string, bool) {
+	row := p.actionTable[state]
+	if column < 0 || column >= len(row) {
+		return "", false
+	}
+	return row[column], true
+}
+
+// syntaxError reports token as one the grammar has no place for
+func syntaxError(token lexical.Token) error {
+	return fmt.Errorf("Syntax error at line %v, column %v: unexpected %v", token.Line, token.Column, lexical.TokenName(token.Type))
+}
+
 func accept(s string) bool {
 	return s == "acc"
 }
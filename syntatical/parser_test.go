@@ -151,6 +151,18 @@ function fibonacci(n : integer) : integer {
 	}`,
 			err: nil,
 		},
+		"test reserved word for as identifier": {
+			program: "function f(n: integer): integer {\n\tvar for: integer;\n}",
+			err:     fmt.Errorf("Syntax error at line 1, column 6: unexpected For"),
+		},
+		"test reserved word return as identifier": {
+			program: "function f(n: integer): integer {\n\tvar return: integer;\n\treturn = n;\n}",
+			err:     fmt.Errorf("Syntax error at line 1, column 6: unexpected Return"),
+		},
+		"test reserved word null as identifier": {
+			program: "function f(n: integer): integer {\n\tvar null: integer;\n\tnull = 1;\n}",
+			err:     fmt.Errorf("Syntax error at line 1, column 6: unexpected Null"),
+		},
 	}
 
 	for name, table := range tt {
@@ -162,3 +174,64 @@ function fibonacci(n : integer) : integer {
 		})
 	}
 }
+
+func TestRunTokensOutsideGrammar(t *testing.T) {
+	tt := map[string]struct {
+		statement string
+		err       error
+	}{
+		"test modulo": {
+			statement: "x = n % 2;",
+			err:       fmt.Errorf("Syntax error at line 2, column 8: unexpected Modulo"),
+		},
+		"test bitwise and": {
+			statement: "x = n & 1;",
+			err:       fmt.Errorf("Syntax error at line 2, column 8: unexpected BitAnd"),
+		},
+		"test bitwise or": {
+			statement: "x = n | 1;",
+			err:       fmt.Errorf("Syntax error at line 2, column 8: unexpected BitOr"),
+		},
+		"test bitwise xor": {
+			statement: "x = n ^ 1;",
+			err:       fmt.Errorf("Syntax error at line 2, column 8: unexpected BitXor"),
+		},
+		"test shift left": {
+			statement: "x = n << 1;",
+			err:       fmt.Errorf("Syntax error at line 2, column 8: unexpected ShiftLeft"),
+		},
+		"test shift right": {
+			statement: "x = n >> 1;",
+			err:       fmt.Errorf("Syntax error at line 2, column 8: unexpected ShiftRight"),
+		},
+		"test plus assignment": {
+			statement: "x += n;",
+			err:       fmt.Errorf("Syntax error at line 2, column 4: unexpected PlusEq"),
+		},
+		"test minus assignment": {
+			statement: "x -= n;",
+			err:       fmt.Errorf("Syntax error at line 2, column 4: unexpected MinusEq"),
+		},
+		"test times assignment": {
+			statement: "x *= n;",
+			err:       fmt.Errorf("Syntax error at line 2, column 4: unexpected TimesEq"),
+		},
+		"test divide assignment": {
+			statement: "x /= n;",
+			err:       fmt.Errorf("Syntax error at line 2, column 4: unexpected DivideEq"),
+		},
+		"test modulo assignment": {
+			statement: "x %= n;",
+			err:       fmt.Errorf("Syntax error at line 2, column 4: unexpected ModuloEq"),
+		},
+	}
+
+	for name, table := range tt {
+		t.Run(name, func(t *testing.T) {
+			syntatical, _ := NewParser()
+			program := "function f(n: integer): integer {\n\tvar x: integer;\n\t" + table.statement + "\n}"
+			err := syntatical.Run(lexical.NewLexer([]byte(program)), "out")
+			assert.Equal(t, table.err, err)
+		})
+	}
+}